@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TempSuffix 写入中的临时文件后缀，载入缓存时会被清理
+const TempSuffix = ".tmp"
+
+// DiskCache 磁盘文件缓存，总大小超过上限时按最近最少使用淘汰
+type DiskCache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List // 最近使用的在前
+	entries map[string]*list.Element
+}
+
+// entry 缓存项
+type entry struct {
+	key  string
+	size int64
+}
+
+// New 创建磁盘缓存并载入目录中已有的文件，maxSize 为 0 表示不限制大小
+func New(dir string, maxSize int64) (*DiskCache, error) {
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &DiskCache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.evict("")
+	c.mu.Unlock()
+	return c, nil
+}
+
+// load 载入已有文件，按修改时间排列使用顺序
+func (c *DiskCache) load() error {
+	type file struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []file
+
+	err := filepath.Walk(c.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		// 上次退出时未写完的文件
+		if strings.HasSuffix(path, TempSuffix) {
+			os.Remove(path)
+			return nil
+		}
+		rel, err := filepath.Rel(c.dir, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, file{key: filepath.ToSlash(rel), size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	for _, f := range files {
+		c.entries[f.key] = c.lru.PushFront(&entry{key: f.key, size: f.size})
+		c.size += f.size
+	}
+	return nil
+}
+
+// Dir 缓存根目录
+func (c *DiskCache) Dir() string {
+	return c.dir
+}
+
+// Path 缓存键对应的文件路径，键使用 / 分隔
+func (c *DiskCache) Path(key string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(key))
+}
+
+// Get 缓存存在时返回文件路径并标记为最近使用
+func (c *DiskCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	path := c.Path(key)
+	// 文件被外部删除
+	if _, err := os.Stat(path); err != nil {
+		c.removeElement(el)
+		return "", false
+	}
+	c.lru.MoveToFront(el)
+	return path, true
+}
+
+// Put 登记已写入 Path(key) 的文件，并淘汰超出上限的旧文件
+func (c *DiskCache) Put(key string) error {
+	info, err := os.Stat(c.Path(key))
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		c.size += info.Size() - e.size
+		e.size = info.Size()
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[key] = c.lru.PushFront(&entry{key: key, size: info.Size()})
+		c.size += info.Size()
+	}
+	c.evict(key)
+	return nil
+}
+
+// Remove 删除单个缓存文件
+func (c *DiskCache) Remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// RemovePrefix 删除键以 prefix 开头的全部缓存，返回删除的文件数
+func (c *DiskCache) RemovePrefix(prefix string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var count int
+	for key, el := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(el)
+			count++
+		}
+	}
+	return count
+}
+
+// Clear 删除全部缓存
+func (c *DiskCache) Clear() int {
+	return c.RemovePrefix("")
+}
+
+// Stats 返回缓存文件数和总大小
+func (c *DiskCache) Stats() (int, int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.size
+}
+
+// evict 淘汰最久未使用的文件直到不超过上限，keep 为刚写入的文件不会被淘汰
+func (c *DiskCache) evict(keep string) {
+	for c.maxSize > 0 && c.size > c.maxSize {
+		el := c.lru.Back()
+		if el == nil || el.Value.(*entry).key == keep {
+			return
+		}
+		c.removeElement(el)
+	}
+}
+
+// removeElement 删除缓存项及其文件，并清理空目录
+func (c *DiskCache) removeElement(el *list.Element) {
+	e := el.Value.(*entry)
+	c.lru.Remove(el)
+	delete(c.entries, e.key)
+	c.size -= e.size
+
+	path := c.Path(e.key)
+	os.Remove(path)
+	for dir := filepath.Dir(path); dir != c.dir && strings.HasPrefix(dir, c.dir); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+}
@@ -7,6 +7,25 @@ import (
 	"time"
 )
 
+// HLSRendition HLS 输出清晰度
+type HLSRendition struct {
+	Name         string
+	Height       int
+	VideoBitrate int // kbps
+	AudioBitrate int // kbps
+}
+
+// ImageProfile 封面/图标的输出规格
+type ImageProfile struct {
+	Name    string
+	Kind    string // cover 或 icon
+	Width   int
+	Height  int
+	Format  string // jpg、png、webp、avif
+	Quality int    // 1~100，0 使用默认值
+	Pad     bool   // 是否填充为固定尺寸
+}
+
 var (
 	// ServerConfig 服务器配置
 	ServerConfig = struct {
@@ -26,6 +45,182 @@ var (
 		Path: "./data/hidevideo.db",
 	}
 
+	// JobConfig 后台任务配置
+	JobConfig = struct {
+		Workers int
+	}{
+		Workers: 2,
+	}
+
+	// MediaConfig 媒体工具配置，路径为空时从 PATH 及常见安装目录查找
+	MediaConfig = struct {
+		FFmpegPath  string
+		FFprobePath string
+	}{
+		FFmpegPath:  "",
+		FFprobePath: "",
+	}
+
+	// ScanConfig 扫描配置
+	ScanConfig = struct {
+		ProbeWorkers int // 并发 ffprobe 数量
+		BatchSize    int // 每个事务写入的视频数量
+	}{
+		ProbeWorkers: 4,
+		BatchSize:    200,
+	}
+
+	// CleanConfig 清理任务配置
+	CleanConfig = struct {
+		DeletedRetention time.Duration // 文件丢失的视频保留时间，期间移回视频库可恢复，超过后彻底删除
+		OrphanGrace      time.Duration // 未被引用的封面、预览和图标文件超过该时间才删除，避免删除正在生成的文件
+	}{
+		DeletedRetention: 30 * 24 * time.Hour,
+		OrphanGrace:      time.Hour,
+	}
+
+	// HLSConfig HLS 切片配置
+	HLSConfig = struct {
+		CacheDir       string
+		CacheSize      int64 // 切片缓存上限（字节），超出后按最近最少使用淘汰
+		SegmentSeconds int
+		Renditions     []HLSRendition // 按清晰度从高到低排列
+	}{
+		CacheDir:       "./data/hls",
+		CacheSize:      5 << 30,
+		SegmentSeconds: 6,
+		Renditions: []HLSRendition{
+			{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 192},
+			{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+			{Name: "480p", Height: 480, VideoBitrate: 1200, AudioBitrate: 96},
+			{Name: "360p", Height: 360, VideoBitrate: 700, AudioBitrate: 96},
+		},
+	}
+
+	// TranscodeConfig 转码配置
+	TranscodeConfig = struct {
+		CacheDir      string
+		CacheSize     int64         // 缓存上限（字节），超出后按最近最少使用淘汰
+		MaxSessions   int           // 播放时同时运行的 ffmpeg 转码进程上限
+		MaxBackground int           // 后台任务（预转码、场景检测等）同时运行的 ffmpeg 进程上限，与播放分开计算
+		QueueTimeout  time.Duration // 等待空闲转码名额的最长时间
+	}{
+		CacheDir:      "./data/transcode",
+		CacheSize:     20 << 30,
+		MaxSessions:   2,
+		MaxBackground: 1,
+		QueueTimeout:  15 * time.Second,
+	}
+
+	// SpriteConfig 拖动预览缩略图配置
+	SpriteConfig = struct {
+		Dir        string
+		Interval   float64 // 截帧间隔（秒）
+		MaxFrames  int     // 单个视频最多截取的帧数，超出时加大间隔
+		TileWidth  int
+		TileHeight int
+		Columns    int // 每张拼图的列数
+		Rows       int // 每张拼图的行数
+	}{
+		Dir:        "./data/sprites",
+		Interval:   10,
+		MaxFrames:  300,
+		TileWidth:  160,
+		TileHeight: 90,
+		Columns:    10,
+		Rows:       10,
+	}
+
+	// IconConfig 视频图标配置，图标保存在数据目录下
+	IconConfig = struct {
+		Dir string
+	}{
+		Dir: filepath.Join(ServerConfig.UploadPath, "icons"),
+	}
+
+	// SubtitleConfig 字幕配置
+	SubtitleConfig = struct {
+		Dir           string // 上传字幕和内嵌字幕提取结果的保存目录
+		MaxUploadSize int64  // 上传字幕文件大小上限（字节）
+	}{
+		Dir:           "./data/subtitles",
+		MaxUploadSize: 10 << 20,
+	}
+
+	// ChapterConfig 章节缩略图配置
+	ChapterConfig = struct {
+		Dir         string
+		ThumbWidth  int
+		ThumbHeight int
+	}{
+		Dir:         "./data/chapters",
+		ThumbWidth:  320,
+		ThumbHeight: 180,
+	}
+
+	// SceneConfig 场景检测配置
+	SceneConfig = struct {
+		Threshold   float64 // 默认场景变化阈值（0~1），视频库可单独设置
+		MinInterval float64 // 相邻章节的最小间隔（秒）
+		MaxScenes   int     // 单个视频最多生成的章节数
+	}{
+		Threshold:   0.4,
+		MinInterval: 30,
+		MaxScenes:   50,
+	}
+
+	// ClipConfig 片段导出配置
+	ClipConfig = struct {
+		Dir               string        // 供下载的片段保存目录
+		MaxLength         float64       // 视频片段的最大时长（秒）
+		MaxAnimatedLength float64       // GIF/WebP 动图的最大时长（秒）
+		AnimatedWidth     int           // 动图宽度
+		AnimatedFPS       int           // 动图帧率
+		Retention         time.Duration // 供下载的片段保留时间，定时删除过期文件
+	}{
+		Dir:               "./data/clips",
+		MaxLength:         600,
+		MaxAnimatedLength: 30,
+		AnimatedWidth:     480,
+		AnimatedFPS:       12,
+		Retention:         24 * time.Hour,
+	}
+
+	// PreviewConfig 悬停预览片段配置
+	PreviewConfig = struct {
+		Format        string  // mp4 或 webp
+		Segments      int     // 截取的片段数
+		SegmentLength float64 // 每个片段的时长（秒）
+		Width         int
+		FPS           int
+	}{
+		Format:        "mp4",
+		Segments:      5,
+		SegmentLength: 1.5,
+		Width:         320,
+		FPS:           15,
+	}
+
+	// ImageConfig 封面/图标多规格配置，同类规格按宽度从小到大排列
+	ImageConfig = struct {
+		Dir          string
+		SourceWidth  int // 从视频截取原图的最大尺寸
+		SourceHeight int
+		Profiles     []ImageProfile
+	}{
+		Dir:          "./data/images",
+		SourceWidth:  1920,
+		SourceHeight: 1080,
+		Profiles: []ImageProfile{
+			{Name: "cover", Kind: "cover", Width: 240, Height: 140, Format: "webp", Quality: 80},
+			{Name: "cover@2x", Kind: "cover", Width: 480, Height: 280, Format: "webp", Quality: 80},
+			{Name: "cover@4x", Kind: "cover", Width: 960, Height: 560, Format: "webp", Quality: 75},
+			{Name: "icon-small", Kind: "icon", Width: 48, Height: 48, Format: "png", Pad: true},
+			{Name: "icon-medium", Kind: "icon", Width: 80, Height: 80, Format: "png", Pad: true},
+			{Name: "icon-medium@2x", Kind: "icon", Width: 160, Height: 160, Format: "png", Pad: true},
+		},
+	}
+
 	// SessionConfig Session配置
 	SessionConfig = struct {
 		Secret string
@@ -24,12 +24,18 @@ func Init() error {
 		&models.User{},
 		&models.VideoLibrary{},
 		&models.Video{},
+		&models.VideoStream{},
+		&models.VideoFormat{},
+		&models.Subtitle{},
+		&models.Chapter{},
 		&models.Tag{},
 		&models.Comment{},
 		&models.VideoTag{},
 		&models.Actor{},
 		&models.VideoActor{},
 		&models.Folder{},
+		&models.Job{},
+		&models.Schedule{},
 	); err != nil {
 		return err
 	}
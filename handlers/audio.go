@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// languageAliases 常见语言的 ISO 639-1 代码对应的 ISO 639-2 代码，媒体文件中两种写法都很常见
+var languageAliases = map[string][]string{
+	"en": {"eng"},
+	"zh": {"chi", "zho", "chs", "cht"},
+	"ja": {"jpn"},
+	"ko": {"kor"},
+	"fr": {"fre", "fra"},
+	"de": {"ger", "deu"},
+	"es": {"spa"},
+	"it": {"ita"},
+	"ru": {"rus"},
+	"pt": {"por"},
+}
+
+// AudioChoice 播放时选择的音轨
+type AudioChoice struct {
+	Stream   int    // 客户端指定的音频流序号，-1 表示未指定
+	Language string // 未指定音轨时优先使用的语言
+}
+
+// defaultAudio 不指定音轨，使用默认音轨
+var defaultAudio = AudioChoice{Stream: -1}
+
+// languageMatches 判断音轨语言是否与偏好语言一致，如 zh-CN、chi、zho 都视为中文
+func languageMatches(trackLanguage, preferred string) bool {
+	normalize := func(lang string) string {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if i := strings.IndexAny(lang, "-_"); i > 0 {
+			lang = lang[:i]
+		}
+		for code, aliases := range languageAliases {
+			if containsCodec(aliases, lang) {
+				return code
+			}
+		}
+		return lang
+	}
+
+	a, b := normalize(trackLanguage), normalize(preferred)
+	return a != "" && a != "und" && a == b
+}
+
+// audioChoice 读取 audio 参数指定的音轨，未指定时使用当前用户偏好的音轨语言
+func audioChoice(c *gin.Context) AudioChoice {
+	choice := AudioChoice{Stream: -1}
+	if stream, err := strconv.Atoi(c.Query("audio")); err == nil && stream >= 0 {
+		choice.Stream = stream
+		return choice
+	}
+
+	if userID, ok := c.Get("user_id"); ok {
+		var user models.User
+		if err := database.DB.Select("id, audio_language").First(&user, userID).Error; err == nil {
+			choice.Language = user.AudioLanguage
+		}
+	}
+	return choice
+}
+
+// GetAudioTracks 获取视频的音轨列表，selected 为按 audio 参数或用户偏好选中的音轨
+func GetAudioTracks(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	streams := loadStreams(&video)
+	_, selected := selectStreams(streams, audioChoice(c))
+
+	list := []gin.H{}
+	for _, s := range streams {
+		if s.Type != "audio" {
+			continue
+		}
+		list = append(list, gin.H{
+			"index":          s.StreamIndex,
+			"codec":          s.Codec,
+			"language":       s.Language,
+			"title":          s.Title,
+			"channels":       s.Channels,
+			"channel_layout": s.ChannelLayout,
+			"default":        s.Default,
+			"selected":       selected != nil && selected.StreamIndex == s.StreamIndex,
+		})
+	}
+	c.JSON(http.StatusOK, list)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// chapterRequest 创建/修改章节参数
+type chapterRequest struct {
+	Title string   `json:"title" binding:"required"`
+	Start *float64 `json:"start" binding:"required"`
+	End   float64  `json:"end"` // 0 表示到下一章节开始
+}
+
+// replaceEmbeddedChapters 用探测结果替换视频的内嵌章节，用户创建的章节保持不变
+func replaceEmbeddedChapters(tx *gorm.DB, videoID uint, data *utils.ProbeData) error {
+	if err := tx.Where("video_id = ? AND source = ?", videoID, utils.ChapterEmbedded).Delete(&models.Chapter{}).Error; err != nil {
+		return err
+	}
+	chapters := data.ChapterRecords()
+	for i := range chapters {
+		chapters[i].VideoID = videoID
+	}
+	if len(chapters) == 0 {
+		return nil
+	}
+	return tx.Create(&chapters).Error
+}
+
+// deleteChapters 删除视频的全部章节和章节缩略图
+func deleteChapters(videoID uint) {
+	database.DB.Where("video_id = ?", videoID).Delete(&models.Chapter{})
+	utils.RemoveChapterFiles(videoID)
+}
+
+// removeChapterThumbnail 没有其他章节使用该起点时删除缩略图
+func removeChapterThumbnail(chapter *models.Chapter) {
+	var count int64
+	database.DB.Model(&models.Chapter{}).Where("video_id = ? AND start = ? AND id != ?", chapter.VideoID, chapter.Start, chapter.ID).Count(&count)
+	if count == 0 {
+		os.Remove(utils.ChapterThumbnailPath(chapter.VideoID, chapter.Start))
+	}
+}
+
+// chapterList 合并内嵌章节和用户章节，按起点排序，未设置结束时间的章节到下一章节开始或视频结束
+func chapterList(video *models.Video, chapters []models.Chapter) []gin.H {
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].Start < chapters[j].Start
+	})
+
+	list := make([]gin.H, 0, len(chapters))
+	for i, ch := range chapters {
+		end := ch.End
+		if end <= ch.Start {
+			end = video.Duration
+			for _, next := range chapters[i+1:] {
+				if next.Start > ch.Start {
+					end = next.Start
+					break
+				}
+			}
+		}
+		list = append(list, gin.H{
+			"id":        ch.ID,
+			"source":    ch.Source,
+			"title":     ch.Title,
+			"start":     ch.Start,
+			"end":       end,
+			"editable":  ch.Source == utils.ChapterUser,
+			"thumbnail": fmt.Sprintf("/api/videos/%d/chapters/%d/thumbnail", video.ID, ch.ID),
+		})
+	}
+	return list
+}
+
+// bindChapter 读取并校验章节参数，失败时已写入响应
+func bindChapter(c *gin.Context, video *models.Video) (*chapterRequest, bool) {
+	var req chapterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入章节标题和开始时间"})
+		return nil, false
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入章节标题"})
+		return nil, false
+	}
+	if *req.Start < 0 || (video.Duration > 0 && *req.Start >= video.Duration) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "开始时间超出视频时长"})
+		return nil, false
+	}
+	if req.End != 0 && (req.End <= *req.Start || (video.Duration > 0 && req.End > video.Duration)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束时间"})
+		return nil, false
+	}
+	return &req, true
+}
+
+// GetChapters 获取视频章节（内嵌章节与用户章节合并），refresh=1 时重新读取内嵌章节
+func GetChapters(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	if c.Query("refresh") == "1" {
+		data, err := utils.Media.Probe(video.Filepath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "读取章节失败: " + err.Error()})
+			return
+		}
+		if err := replaceEmbeddedChapters(database.DB, video.ID, data); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存章节失败"})
+			return
+		}
+	}
+
+	var chapters []models.Chapter
+	database.DB.Where("video_id = ?", video.ID).Find(&chapters)
+	c.JSON(http.StatusOK, chapterList(&video, chapters))
+}
+
+// AddChapter 添加用户章节
+func AddChapter(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	req, ok := bindChapter(c, &video)
+	if !ok {
+		return
+	}
+
+	chapter := models.Chapter{
+		VideoID: video.ID,
+		Source:  utils.ChapterUser,
+		Title:   req.Title,
+		Start:   *req.Start,
+		End:     req.End,
+		UserID:  c.GetUint("user_id"),
+	}
+	if err := database.DB.Create(&chapter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加章节失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "章节已添加", "chapter": chapter})
+}
+
+// UpdateChapter 修改用户章节，内嵌章节不能修改
+func UpdateChapter(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	var chapter models.Chapter
+	if err := database.DB.Where("video_id = ?", video.ID).First(&chapter, c.Param("cid")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "章节不存在"})
+		return
+	}
+	if chapter.Source != utils.ChapterUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能修改用户创建的章节"})
+		return
+	}
+
+	req, ok := bindChapter(c, &video)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{
+		"title": req.Title,
+		"start": *req.Start,
+		"end":   req.End,
+	}
+	if *req.Start != chapter.Start {
+		removeChapterThumbnail(&chapter)
+		updates["thumbnail_path"] = ""
+	}
+	if err := database.DB.Model(&chapter).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改章节失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "章节已修改", "chapter": chapter})
+}
+
+// DeleteChapter 删除用户章节，内嵌章节不能删除
+func DeleteChapter(c *gin.Context) {
+	var chapter models.Chapter
+	if err := database.DB.Where("video_id = ?", c.Param("id")).First(&chapter, c.Param("cid")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "章节不存在"})
+		return
+	}
+	if chapter.Source != utils.ChapterUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能删除用户创建的章节"})
+		return
+	}
+
+	removeChapterThumbnail(&chapter)
+	if err := database.DB.Delete(&chapter).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除章节失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// GetChapterThumbnail 输出章节缩略图，首次请求时截取章节起点的画面
+func GetChapterThumbnail(c *gin.Context) {
+	var chapter models.Chapter
+	if err := database.DB.Where("video_id = ?", c.Param("id")).First(&chapter, c.Param("cid")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "章节不存在"})
+		return
+	}
+
+	path := chapter.ThumbnailPath
+	if path == "" || !utils.FileExists(path) {
+		var video models.Video
+		if err := database.DB.First(&video, chapter.VideoID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+			return
+		}
+		var err error
+		path, err = utils.GenerateChapterThumbnail(video.Filepath, video.ID, chapter.Start)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "缩略图生成失败: " + err.Error()})
+			return
+		}
+		database.DB.Model(&chapter).Update("thumbnail_path", path)
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(path)
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 片段保存位置
+const (
+	ClipTargetLibrary  = "library"  // 保存到视频库目录并入库
+	ClipTargetDownload = "download" // 保存为供下载的文件
+)
+
+// clipJobPayload 片段导出任务参数
+type clipJobPayload struct {
+	VideoID   uint    `json:"video_id"`
+	Start     float64 `json:"start"`
+	End       float64 `json:"end"`
+	Format    string  `json:"format"`
+	Target    string  `json:"target"`
+	LibraryID uint    `json:"library_id,omitempty"`
+	Folder    string  `json:"folder,omitempty"` // 保存到视频库时的目标目录（绝对路径）
+	Filename  string  `json:"filename"`
+}
+
+// clipFolder 解析视频库中的目标目录，为空时使用视频库根目录，目录（包括符号链接指向的目录）不在视频库内时返回错误
+func clipFolder(library *models.VideoLibrary, folder string) (string, error) {
+	root, err := filepath.Abs(library.Path)
+	if err != nil {
+		return "", err
+	}
+	if folder == "" {
+		folder = root
+	} else if !filepath.IsAbs(folder) {
+		folder = filepath.Join(root, folder)
+	}
+	folder, err = filepath.Abs(folder)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(folder)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("目录不存在")
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("视频库目录不存在")
+	}
+	realFolder, err := filepath.EvalSymlinks(folder)
+	if err != nil {
+		return "", fmt.Errorf("目录不存在")
+	}
+
+	rel, err := filepath.Rel(realRoot, realFolder)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("无效的目录")
+	}
+	return folder, nil
+}
+
+// StartClipCleaner 定期删除超过保留时间的下载片段，不依赖清理任务的定时计划
+func StartClipCleaner() {
+	go func() {
+		for {
+			if n := utils.RemoveExpiredClips(config.ClipConfig.Retention); n > 0 {
+				log.Printf("已删除 %d 个过期的导出片段", n)
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
+
+// CreateClip 截取视频片段导出为新文件（创建后台任务）
+// format: copy（复制流，速度快但起点对齐到关键帧）、mp4（重新编码）、gif、webp
+// target: library 保存到视频库目录并入库（仅视频格式），download 保存为供下载的文件
+func CreateClip(c *gin.Context) {
+	var req clipJobPayload
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+	if _, err := os.Stat(video.Filepath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频文件不存在"})
+		return
+	}
+
+	// 默认格式和保存位置
+	if req.Format == "" {
+		req.Format = utils.ClipCopy
+	}
+	if req.Target == "" {
+		req.Target = ClipTargetDownload
+	}
+	if utils.ClipExt(req.Format) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式"})
+		return
+	}
+
+	if req.Start < 0 || req.End <= req.Start || (video.Duration > 0 && req.Start >= video.Duration) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的起止时间"})
+		return
+	}
+	if video.Duration > 0 && req.End > video.Duration {
+		req.End = video.Duration
+	}
+	maxLength := config.ClipConfig.MaxLength
+	if utils.IsAnimatedClip(req.Format) {
+		maxLength = config.ClipConfig.MaxAnimatedLength
+	}
+	if maxLength > 0 && req.End-req.Start > maxLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("片段时长不能超过 %.0f 秒", maxLength)})
+		return
+	}
+
+	libraryID := video.LibraryID
+	switch req.Target {
+	case ClipTargetLibrary:
+		if utils.IsAnimatedClip(req.Format) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "动图只能保存为下载文件"})
+			return
+		}
+		if req.LibraryID == 0 {
+			req.LibraryID = video.LibraryID
+		}
+		var library models.VideoLibrary
+		if err := database.DB.First(&library, req.LibraryID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+			return
+		}
+		folder, err := clipFolder(&library, req.Folder)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// 短于视频库最小时长的片段不会入库
+		if library.MinDuration > 0 && req.End-req.Start < library.MinDuration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("片段时长低于视频库的最小时长 %.0f 秒", library.MinDuration)})
+			return
+		}
+		req.Folder = folder
+		libraryID = library.ID
+	case ClipTargetDownload:
+		req.LibraryID, req.Folder = 0, ""
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的保存位置"})
+		return
+	}
+
+	req.VideoID = video.ID
+	req.Filename = utils.ClipFilename(req.Filename, video.Filename, req.Format, req.Start, req.End)
+	enqueueJob(c, JobTypeClip, libraryID, req, "片段导出任务已创建")
+}
+
+// runExportClip 执行片段导出任务
+func runExportClip(t *jobs.Task) (interface{}, error) {
+	var req clipJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var video models.Video
+	if err := database.DB.First(&video, req.VideoID).Error; err != nil {
+		return nil, fmt.Errorf("视频不存在")
+	}
+
+	dir := req.Folder
+	if req.Target != ClipTargetLibrary {
+		dir = utils.ClipDownloadDir(t.Job.ID)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	t.SetTotal(1)
+	// 临时文件的扩展名不是视频格式，导出过程中不会被监听入库
+	tmp := filepath.Join(dir, fmt.Sprintf("%s.%d.tmp", req.Filename, t.Job.ID))
+	inputArgs, args := utils.ClipArgs(req.Format, req.Start, req.End-req.Start)
+	err := transcode.Run(t.Ctx, transcode.Session{Kind: transcode.KindClip, VideoID: video.ID, Mode: req.Format}, utils.TranscodeOptions{
+		InputArgs: inputArgs,
+		Input:     video.Filepath,
+		Args:      args,
+		Output:    tmp,
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	// 保存到视频库时在索引锁内移动文件并入库，监听到新文件时等待入库完成，避免重复入库
+	if req.Target == ClipTargetLibrary {
+		defer lockLibrary(req.LibraryID)()
+	}
+	// 导出完成后才占用文件名，同名文件已存在（包括同时导出的片段）时追加序号
+	path, err := utils.ReservePath(filepath.Join(dir, req.Filename))
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		os.Remove(path)
+		return nil, err
+	}
+	t.Step(path, nil)
+
+	result := gin.H{
+		"filename": filepath.Base(path),
+		"format":   req.Format,
+		"start":    req.Start,
+		"end":      req.End,
+	}
+
+	if req.Target != ClipTargetLibrary {
+		result["download"] = fmt.Sprintf("/api/clips/%d", t.Job.ID)
+		return result, nil
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, req.LibraryID).Error; err != nil {
+		return nil, fmt.Errorf("视频库不存在")
+	}
+	clip, _, err := indexNewFile(&library, path, func(string, int64) []models.Video { return nil })
+	if err != nil {
+		return nil, fmt.Errorf("片段已保存但入库失败: %v", err)
+	}
+	result["path"] = path
+	result["video_id"] = clip.ID
+	return result, nil
+}
+
+// DownloadClip 下载导出的片段，id 为导出任务 ID
+func DownloadClip(c *gin.Context) {
+	var job models.Job
+	if err := database.DB.Where("type = ?", JobTypeClip).First(&job, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "导出任务不存在"})
+		return
+	}
+
+	entries, err := os.ReadDir(utils.ClipDownloadDir(job.ID))
+	if err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || strings.HasSuffix(entry.Name(), ".tmp") {
+				continue
+			}
+			c.FileAttachment(filepath.Join(utils.ClipDownloadDir(job.ID), entry.Name()), entry.Name())
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "片段不存在或已过期"})
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/models"
+)
+
+func TestClipFolder(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "clips"), 0755)
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	library := &models.VideoLibrary{Path: root}
+
+	valid := map[string]string{
+		"":                           root,
+		"clips":                      filepath.Join(root, "clips"),
+		filepath.Join(root, "clips"): filepath.Join(root, "clips"),
+		"clips/../clips":             filepath.Join(root, "clips"),
+	}
+	for folder, want := range valid {
+		got, err := clipFolder(library, folder)
+		if err != nil || got != want {
+			t.Errorf("clipFolder(%q) = %q, %v; want %q", folder, got, err, want)
+		}
+	}
+
+	for _, folder := range []string{"..", "../" + filepath.Base(outside), outside, "escape", "missing"} {
+		if got, err := clipFolder(library, folder); err == nil {
+			t.Errorf("clipFolder(%q) = %q, want error", folder, got)
+		}
+	}
+}
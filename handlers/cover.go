@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCoverCandidates 单次最多截取的候选封面数量
+const maxCoverCandidates = 24
+
+// coverImageExtensions 允许上传的封面图片格式
+var coverImageExtensions = []string{".jpg", ".jpeg", ".png", ".webp", ".bmp", ".gif"}
+
+// candidateURL 将候选帧路径转换为 URL 路径
+func candidateURL(videoID uint, path string) string {
+	return fmt.Sprintf("/covers/candidates/%d/%s", videoID, filepath.Base(path))
+}
+
+// candidateList 转换为响应格式
+func candidateList(videoID uint, candidates []utils.CoverCandidate) []gin.H {
+	list := make([]gin.H, 0, len(candidates))
+	for _, candidate := range candidates {
+		list = append(list, gin.H{
+			"second": candidate.Second,
+			"score":  candidate.Score,
+			"url":    candidateURL(videoID, candidate.Path),
+		})
+	}
+	return list
+}
+
+// setVideoCover 更新视频封面并删除旧的封面文件
+func setVideoCover(video *models.Video, coverPath string) error {
+	// Update 会同步修改 video.CoverPath，先记下旧封面
+	oldPath := video.CoverPath
+	if err := database.DB.Model(video).Update("cover_path", coverPath).Error; err != nil {
+		return err
+	}
+	if oldPath != "" && oldPath != coverPath {
+		os.Remove(oldPath)
+	}
+	video.CoverPath = coverPath
+	onCoverChanged(video)
+	return nil
+}
+
+// GetCoverCandidates 获取视频已生成的候选封面
+func GetCoverCandidates(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, candidateList(video.ID, utils.ListCoverCandidates(video.ID)))
+}
+
+// candidateJobPayload 候选封面生成任务参数
+type candidateJobPayload struct {
+	VideoID uint   `json:"video_id"`
+	Count   int    `json:"count"`
+	Mode    string `json:"mode"` // "interval" 均匀截取, "scene" 按画面变化挑选
+}
+
+// GenerateCoverCandidates 截取多个候选封面（创建后台任务），完成后通过 GetCoverCandidates 获取
+func GenerateCoverCandidates(c *gin.Context) {
+	var req candidateJobPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
+		}
+	}
+	if req.Count <= 0 {
+		req.Count = 6
+	}
+	if req.Count > maxCoverCandidates {
+		req.Count = maxCoverCandidates
+	}
+
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	req.VideoID = video.ID
+	enqueueJob(c, JobTypeCandidates, video.LibraryID, req, "候选封面生成任务已创建")
+}
+
+// runGenerateCoverCandidates 执行候选封面生成任务，截帧占用后台转码名额
+func runGenerateCoverCandidates(t *jobs.Task) (interface{}, error) {
+	var req candidateJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var video models.Video
+	if err := database.DB.First(&video, req.VideoID).Error; err != nil {
+		return nil, fmt.Errorf("视频不存在")
+	}
+
+	t.SetTotal(1)
+	var candidates []utils.CoverCandidate
+	err := transcode.Do(t.Ctx, transcode.Session{Kind: transcode.KindFrames, VideoID: video.ID, Mode: "candidates"}, func(ctx context.Context) error {
+		var err error
+		candidates, err = utils.GenerateCoverCandidates(ctx, video.Filepath, video.ID, video.Duration, req.Count, req.Mode == "scene")
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("生成候选封面失败: %v", err)
+	}
+	t.Step(video.Filepath, nil)
+
+	return gin.H{
+		"video_id":   video.ID,
+		"candidates": candidateList(video.ID, candidates),
+	}, nil
+}
+
+// SetVideoCover 将指定时间点的画面设为封面，优先使用已生成的候选帧（也用于播放器当前画面）
+func SetVideoCover(c *gin.Context) {
+	var req struct {
+		Second *float64 `json:"second" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || *req.Second < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择封面时间点"})
+		return
+	}
+
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	var coverPath string
+	candidate := utils.CandidatePath(video.ID, *req.Second)
+	if data, err := os.ReadFile(candidate); err == nil {
+		coverPath = utils.AutoCoverPath(video.ID, *req.Second)
+		if err := os.WriteFile(coverPath, data, 0644); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存封面失败"})
+			return
+		}
+	} else {
+		coverPath, err = utils.GenerateCover(video.Filepath, video.ID, *req.Second)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "封面生成失败: " + err.Error()})
+			return
+		}
+	}
+
+	if err := setVideoCover(&video, coverPath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新封面失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "封面已更新",
+		"cover_path": "/covers/" + getCoverFilename(coverPath),
+	})
+}
+
+// UploadVideoCover 上传自定义封面
+func UploadVideoCover(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择图片"})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !containsCodec(coverImageExtensions, ext) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的图片格式"})
+		return
+	}
+
+	coverDir := config.ServerConfig.StaticPath
+	tmpPath := filepath.Join(coverDir, fmt.Sprintf("upload_%d_%d%s", video.ID, time.Now().UnixNano(), ext))
+	if err := c.SaveUploadedFile(file, tmpPath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存图片失败"})
+		return
+	}
+	defer os.Remove(tmpPath)
+
+	// 统一缩放为与自动封面相同的尺寸，文件名带时间戳避免浏览器缓存旧封面
+	coverPath := filepath.Join(coverDir, fmt.Sprintf("cover_%d_u%d.jpg", video.ID, time.Now().Unix()))
+	width, height := utils.CoverSize()
+	if err := utils.Media.ResizeImage(tmpPath, coverPath, utils.ImageOptions{Width: width, Height: height}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法识别的图片: " + err.Error()})
+		return
+	}
+
+	if err := setVideoCover(&video, coverPath); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新封面失败"})
+		return
+	}
+
+	// 保留上传图片的高分辨率版本作为多规格图片的原图，已有图标时按原图重新生成
+	if err := os.MkdirAll(utils.ImageDir(video.ID), 0755); err == nil {
+		err := utils.Media.ResizeImage(tmpPath, utils.ImageSourcePath(video.ID), utils.ImageOptions{
+			Width:  config.ImageConfig.SourceWidth,
+			Height: config.ImageConfig.SourceHeight,
+			Format: "jpg",
+		})
+		if err == nil && video.IconPath != "" {
+			generateIconFiles(&video)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "封面已更新",
+		"cover_path": "/covers/" + getCoverFilename(coverPath),
+	})
+}
@@ -20,6 +20,7 @@ type FileInfo struct {
 	Size      int64     `json:"size"`
 	ModTime   time.Time `json:"modTime"`
 	Extension string    `json:"extension,omitempty"`
+	IsVideo   bool      `json:"isVideo"`
 }
 
 // ListLibraryFiles 列出视频库目录下的文件
@@ -75,6 +76,14 @@ func ListLibraryFiles(c *gin.Context) {
 		return
 	}
 
+	// 按视频库的扫描设置过滤文件
+	var libraryModel models.VideoLibrary
+	database.DB.First(&libraryModel, library.ID)
+	filter, err := libraryScanFilter(libraryModel)
+	if err != nil {
+		filter = nil
+	}
+
 	var files []FileInfo
 	for _, entry := range entries {
 		fullPath := filepath.Join(dirPath, entry.Name())
@@ -84,15 +93,34 @@ func ListLibraryFiles(c *gin.Context) {
 			continue
 		}
 
+		// 与扫描一致：符号链接按目标文件的大小过滤，指向目录的符号链接仅在开启跟随时列出
+		if fileInfo.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Stat(fullPath)
+			if err != nil || (target.IsDir() && !filter.FollowSymlinks()) {
+				continue
+			}
+			fileInfo = target
+		}
+		isDir := fileInfo.IsDir()
+
+		if filter.Excluded(library.Path, fullPath, isDir) {
+			continue
+		}
+		isVideo := !isDir && filter.IsVideoFile(fullPath)
+		if isVideo && !filter.AcceptFile(library.Path, fullPath, fileInfo) {
+			continue
+		}
+
 		f := FileInfo{
 			Name:    entry.Name(),
 			Path:    fullPath,
-			IsDir:   entry.IsDir(),
+			IsDir:   isDir,
 			Size:    fileInfo.Size(),
 			ModTime: fileInfo.ModTime(),
+			IsVideo: isVideo,
 		}
 
-		if !entry.IsDir() {
+		if !isDir {
 			f.Extension = filepath.Ext(entry.Name())
 		}
 
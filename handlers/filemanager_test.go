@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hidevideo/backend/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestListLibraryFilesFollowsSymlinkSize(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{"small.mp4": "tiny"})
+	database.DB.Model(library).Update("min_file_size", 100)
+
+	// 符号链接本身很小，按目标文件的大小过滤
+	target := filepath.Join(t.TempDir(), "large.mp4")
+	writeTestFile(t, target, strings.Repeat("x", 200))
+	if err := os.Symlink(target, filepath.Join(library.Path, "link.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(library.Path, "small.mp4"), filepath.Join(library.Path, "small-link.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(library.Path, "missing.mp4"), filepath.Join(library.Path, "broken.mp4")); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(library.ID)}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	ListLibraryFiles(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	var resp struct {
+		Files []FileInfo `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].Name != "link.mp4" || resp.Files[0].Size != 200 {
+		t.Errorf("files = %+v, want only link.mp4 with the target size", resp.Files)
+	}
+}
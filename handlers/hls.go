@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/hls"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hlsSource 加载视频并构造切片源，按 audio 参数或用户偏好选择音轨，失败时已写入响应
+func hlsSource(c *gin.Context) (*hls.Source, bool) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return nil, false
+	}
+
+	if _, err := os.Stat(video.Filepath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频文件不存在"})
+		return nil, false
+	}
+
+	if video.Duration <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法获取视频时长"})
+		return nil, false
+	}
+
+	src := &hls.Source{
+		VideoID:     video.ID,
+		Path:        video.Filepath,
+		Version:     video.ModTime.Unix(),
+		Duration:    video.Duration,
+		Width:       video.Width,
+		Height:      video.Height,
+		VideoStream: -1,
+		AudioStream: -1,
+	}
+	// 使用第一个音轨时不需要指定，沿用原有的切片缓存
+	streams := loadStreams(&video)
+	videoStream, audio := selectStreams(streams, audioChoice(c))
+	if videoStream != nil {
+		src.VideoStream = videoStream.StreamIndex
+	}
+	// 没有流信息时按有音轨处理
+	src.Audio = audio != nil || len(streams) == 0
+	if audio != nil {
+		for _, s := range streams {
+			if s.Type == "audio" {
+				if s.StreamIndex != audio.StreamIndex {
+					src.AudioStream = audio.StreamIndex
+				}
+				break
+			}
+		}
+	}
+	return src, true
+}
+
+// GetHLSMaster 获取 HLS 主播放列表
+func GetHLSMaster(c *gin.Context) {
+	src, ok := hlsSource(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(hls.MasterPlaylist(src)))
+}
+
+// GetHLSPlaylist 获取单个清晰度的 HLS 播放列表
+func GetHLSPlaylist(c *gin.Context) {
+	src, ok := hlsSource(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := hls.FindRendition(src, c.Param("rendition")); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "清晰度不存在"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(hls.MediaPlaylist(src)))
+}
+
+// GetHLSSegment 获取 HLS 分片，未缓存时按需转码，并在有空闲名额时预取下一个分片
+func GetHLSSegment(c *gin.Context) {
+	src, ok := hlsSource(c)
+	if !ok {
+		return
+	}
+
+	rendition, ok := hls.FindRendition(src, c.Param("rendition"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "清晰度不存在"})
+		return
+	}
+
+	name := c.Param("segment")
+	index, err := strconv.Atoi(strings.TrimSuffix(name, ".ts"))
+	if err != nil || !strings.HasSuffix(name, ".ts") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分片"})
+		return
+	}
+
+	path, err := hls.Segment(c.Request.Context(), src, rendition, index)
+	if err == hls.ErrSegmentRange {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分片不存在"})
+		return
+	}
+	if err == transcode.ErrBusy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "分片转码失败: " + err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "video/mp2t")
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.File(path)
+
+	// 响应已完整发送，播放器可以继续请求；在连接保持期间预取下一个分片，客户端断开时终止
+	c.Writer.Flush()
+	hls.Prefetch(c.Request.Context(), src, rendition, index+1)
+}
@@ -4,17 +4,18 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 
 	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
 	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-// GenerateIcon 生成视频图标（小、中两种尺寸）
+// GenerateIcon 生成视频图标（创建后台任务）
 func GenerateIcon(c *gin.Context) {
 	libraryID := c.Param("id")
 
@@ -25,71 +26,52 @@ func GenerateIcon(c *gin.Context) {
 		return
 	}
 
+	enqueueJob(c, JobTypeIcon, library.ID, nil, "图标生成任务已创建")
+}
+
+// runGenerateIcon 执行图标生成任务（按图片配置中的 icon 规格）
+func runGenerateIcon(t *jobs.Task) (interface{}, error) {
 	// 获取该视频库下的所有视频
 	var videos []models.Video
-	database.DB.Where("library_id = ?", libraryID).Find(&videos)
-
-	// 确保图标目录存在
-	iconDir := "./backend/data/icon"
-	if err := os.MkdirAll(iconDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建图标目录失败"})
-		return
-	}
-
-	// 查找 ffmpeg
-	ffmpegPath := findFFmpeg()
-	if ffmpegPath == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "未找到 ffmpeg"})
-		return
-	}
+	database.DB.Where("library_id = ?", t.Job.LibraryID).Find(&videos)
 
 	var successCount int
 	var failCount int
 
+	t.SetTotal(len(videos))
 	for _, video := range videos {
+		if t.Cancelled() {
+			break
+		}
+
 		// 检查是否有封面
 		if video.CoverPath == "" {
 			failCount++
+			t.Step(video.Filepath, fmt.Errorf("视频没有封面"))
 			continue
 		}
 
 		// 检查封面文件是否存在
 		if _, err := os.Stat(video.CoverPath); os.IsNotExist(err) {
 			failCount++
+			t.Step(video.Filepath, fmt.Errorf("封面文件不存在"))
 			continue
 		}
 
-		// 生成小图标（48x48）
-		smallIconPath := filepath.Join(iconDir, fmt.Sprintf("icon_%d_small.png", video.ID))
-		smallCmd := exec.Command(ffmpegPath, "-i", video.CoverPath, "-vf", "scale=48:48:force_original_aspect_ratio=decrease,pad=48:48:(ow-iw)/2:(oh-ih)/2:black", "-y", smallIconPath)
-		smallCmd.Run()
-
-		// 生成中图标（80x80）
-		mediumIconPath := filepath.Join(iconDir, fmt.Sprintf("icon_%d_medium.png", video.ID))
-		mediumCmd := exec.Command(ffmpegPath, "-i", video.CoverPath, "-vf", "scale=80:80:force_original_aspect_ratio=decrease,pad=80:80:(ow-iw)/2:(oh-ih)/2:black", "-y", mediumIconPath)
-		mediumCmd.Run()
-
-		// 检查图标是否生成成功
-		if _, err := os.Stat(smallIconPath); err == nil {
-			if _, err := os.Stat(mediumIconPath); err == nil {
-				// 更新数据库中的图标路径
-				database.DB.Model(&video).Update("icon_path", iconDir)
-				successCount++
-			} else {
-				failCount++
-			}
-		} else {
+		if _, err := generateIconFiles(&video); err != nil {
 			failCount++
+			t.Step(video.Filepath, err)
+			continue
 		}
+		successCount++
+		t.Step(video.Filepath, nil)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":   "图标生成完成",
-		"success":   successCount,
-		"failed":    failCount,
-		"total":     len(videos),
-		"icon_path": iconDir,
-	})
+	return gin.H{
+		"success": successCount,
+		"failed":  failCount,
+		"total":   len(videos),
+	}, nil
 }
 
 // GenerateSingleIcon 生成单个视频的图标
@@ -120,60 +102,43 @@ func GenerateSingleIcon(c *gin.Context) {
 		return
 	}
 
-	// 确保图标目录存在
-	iconDir := "./backend/data/icon"
-	if err := os.MkdirAll(iconDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建图标目录失败"})
-		return
-	}
-
-	// 查找 ffmpeg
-	ffmpegPath := findFFmpeg()
-	if ffmpegPath == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "未找到 ffmpeg"})
+	iconPath, err := generateIconFiles(&video)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "图标生成失败: " + err.Error()})
 		return
 	}
 
-	// 生成小图标（48x48）
-	smallIconPath := filepath.Join(iconDir, fmt.Sprintf("icon_%d_small.png", video.ID))
-	smallCmd := exec.Command(ffmpegPath, "-i", video.CoverPath, "-vf", "scale=48:48:force_original_aspect_ratio=decrease,pad=48:48:(ow-iw)/2:(oh-ih)/2:black", "-y", smallIconPath)
-	smallCmd.Run()
-
-	// 生成中图标（80x80）
-	mediumIconPath := filepath.Join(iconDir, fmt.Sprintf("icon_%d_medium.png", video.ID))
-	mediumCmd := exec.Command(ffmpegPath, "-i", video.CoverPath, "-vf", "scale=80:80:force_original_aspect_ratio=decrease,pad=80:80:(ow-iw)/2:(oh-ih)/2:black", "-y", mediumIconPath)
-	mediumCmd.Run()
-
-	// 检查是否生成成功
-	if _, err := os.Stat(smallIconPath); err == nil {
-		if _, err := os.Stat(mediumIconPath); err == nil {
-			// 更新数据库中的图标路径
-			database.DB.Model(&video).Update("icon_path", iconDir)
-
-			c.JSON(http.StatusOK, gin.H{
-				"message":   "图标生成成功",
-				"icon_path": iconDir,
-			})
-			return
-		}
+	icons := gin.H{}
+	for _, p := range utils.ImageProfiles("icon") {
+		icons[p.Name] = iconURL(utils.IconPath(video.ID, p))
 	}
-
-	c.JSON(http.StatusInternalServerError, gin.H{"error": "图标生成失败"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "图标生成成功",
+		"icon_path": iconURL(iconPath),
+		"icons":     icons,
+	})
 }
 
-// findFFmpeg 查找 ffmpeg 路径
-func findFFmpeg() string {
-	paths := []string{
-		"/usr/bin/ffmpeg",
-		"/usr/local/bin/ffmpeg",
-		"/opt/homebrew/bin/ffmpeg",
+// generateIconFiles 根据封面原图生成各规格图标并更新数据库中的图标路径，没有原图时使用封面
+func generateIconFiles(video *models.Video) (string, error) {
+	source, err := utils.EnsureImageSource(video)
+	if err != nil {
+		source = video.CoverPath
 	}
-
-	for _, p := range paths {
-		if _, err := os.Stat(p); err == nil {
-			return p
-		}
+	iconPath, err := utils.GenerateIcons(source, video.ID)
+	if err != nil {
+		return "", err
 	}
+	if err := database.DB.Model(video).Update("icon_path", iconPath).Error; err != nil {
+		return "", err
+	}
+	return iconPath, nil
+}
 
-	return ""
+// iconURL 将图标文件路径转换为 URL
+func iconURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	return "/icons/" + filepath.Base(path)
 }
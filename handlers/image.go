@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageJobPayload 多规格图片生成任务参数
+type imageJobPayload struct {
+	Mode string `json:"mode"` // "new" 仅生成缺少的规格, "reset" 全部重新生成
+}
+
+// imageContentTypes 图片格式对应的 Content-Type
+var imageContentTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+	"avif": "image/avif",
+}
+
+// onCoverChanged 封面变化后删除旧的多规格图片（下次请求时重新生成），已生成过图标的视频重新生成图标
+func onCoverChanged(video *models.Video) {
+	utils.RemoveImageVariants(video.ID)
+	if video.IconPath != "" {
+		generateIconFiles(video)
+	}
+}
+
+// GetVideoImages 获取视频可用的图片规格，供前端构建 srcset
+func GetVideoImages(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	list := []gin.H{}
+	for _, p := range utils.ImageProfiles(c.Query("kind")) {
+		list = append(list, gin.H{
+			"name":   p.Name,
+			"kind":   p.Kind,
+			"width":  p.Width,
+			"height": p.Height,
+			"format": p.Format,
+			"url":    fmt.Sprintf("/api/videos/%d/image?profile=%s", video.ID, p.Name),
+		})
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetVideoImage 输出指定规格的图片，按 width 参数选择最合适的规格，缺少时由后台任务生成
+func GetVideoImage(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	var profile config.ImageProfile
+	found := false
+	if name := c.Query("profile"); name != "" {
+		for _, p := range utils.ImageProfiles("") {
+			if p.Name == name {
+				profile, found = p, true
+				break
+			}
+		}
+	} else {
+		width, _ := strconv.Atoi(c.Query("width"))
+		profile, found = utils.BestImageProfile(c.DefaultQuery("kind", "cover"), width)
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "图片规格不存在"})
+		return
+	}
+
+	// 图标只保存在图标目录中
+	path := utils.ImageVariantPath(video.ID, profile)
+	if profile.Kind == "icon" {
+		path = utils.IconPath(video.ID, profile)
+	}
+
+	// 尚未生成的规格不在请求中调用 ffmpeg，先返回原封面并创建后台生成任务
+	if !utils.FileExists(path) {
+		if video.CoverPath == "" || !utils.FileExists(video.CoverPath) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "视频没有封面"})
+			return
+		}
+		if profile.Kind == "cover" {
+			if err := enqueuePendingJob(JobTypeImages, video.LibraryID, imageJobPayload{Mode: "new"}); err != nil {
+				log.Printf("创建图片生成任务失败: %v", err)
+			}
+		}
+		c.Header("Cache-Control", "no-cache")
+		c.File(video.CoverPath)
+		return
+	}
+
+	if contentType, ok := imageContentTypes[profile.Format]; ok {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.File(path)
+}
+
+// RegenerateImages 重新生成视频库的多规格封面和图标（创建后台任务）
+func RegenerateImages(c *gin.Context) {
+	var req imageJobPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
+		}
+	}
+
+	// 默认模式
+	if req.Mode == "" {
+		req.Mode = "reset"
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	enqueueJob(c, JobTypeImages, library.ID, req, "图片生成任务已创建")
+}
+
+// runRegenerateImages 执行多规格图片生成任务
+func runRegenerateImages(t *jobs.Task) (interface{}, error) {
+	var req imageJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var videos []models.Video
+	database.DB.Where("library_id = ? AND cover_path != ''", t.Job.LibraryID).Find(&videos)
+
+	profiles := utils.ImageProfiles("cover")
+	var successCount int
+	var failCount int
+	var variantCount int
+	var iconCount int
+
+	t.SetTotal(len(videos))
+	for _, video := range videos {
+		if t.Cancelled() {
+			break
+		}
+
+		if req.Mode != "new" {
+			utils.RemoveImageVariants(video.ID)
+		}
+
+		var stepErr error
+		for _, p := range profiles {
+			if _, err := utils.RenderImageVariant(&video, p, false); err != nil {
+				stepErr = fmt.Errorf("%s: %v", p.Name, err)
+				break
+			}
+			variantCount++
+		}
+
+		// 已生成过图标的视频按当前的图标规格重新生成，图标目录中的文件和 icon_path 保持一致
+		if stepErr == nil && video.IconPath != "" && (req.Mode != "new" || iconsMissing(video.ID)) {
+			if _, err := generateIconFiles(&video); err != nil {
+				stepErr = fmt.Errorf("图标: %v", err)
+			} else {
+				iconCount++
+			}
+		}
+
+		if stepErr != nil {
+			failCount++
+		} else {
+			successCount++
+		}
+		t.Step(video.Filepath, stepErr)
+	}
+
+	return gin.H{
+		"success":  successCount,
+		"failed":   failCount,
+		"total":    len(videos),
+		"variants": variantCount,
+		"icons":    iconCount,
+	}, nil
+}
+
+// iconsMissing 是否缺少某个规格的图标
+func iconsMissing(videoID uint) bool {
+	for _, path := range utils.IconFiles(videoID) {
+		if !utils.FileExists(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestImage 请求视频指定规格的图片
+func requestImage(video *models.Video, profile string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(video.ID)}}
+	c.Request = httptest.NewRequest(http.MethodGet, "/?profile="+profile, nil)
+	GetVideoImage(c)
+	return w
+}
+
+func TestVideoImageGeneratedByJob(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{"movie.mp4": "image movie"})
+	scanLibrary(t, library)
+	video := findVideo(t, filepath.Join(library.Path, "movie.mp4"))
+
+	coverPath, err := utils.GenerateCover(video.Filepath, video.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DB.Model(&video).Updates(map[string]interface{}{"cover_path": coverPath, "icon_path": "old"})
+
+	// 尚未生成的规格返回原封面，并只创建一个生成任务
+	for i := 0; i < 2; i++ {
+		if w := requestImage(&video, "cover@2x"); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-cache" {
+			t.Fatalf("missing variant: status %d, cache %q", w.Code, w.Header().Get("Cache-Control"))
+		}
+	}
+	var queued []models.Job
+	database.DB.Where("type = ? AND library_id = ?", JobTypeImages, library.ID).Find(&queued)
+	if len(queued) != 1 {
+		t.Fatalf("%d image jobs queued, want 1", len(queued))
+	}
+
+	result, err := runRegenerateImages(&jobs.Task{Ctx: context.Background(), Job: &queued[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := result.(gin.H); r["failed"] != 0 || r["icons"] != 1 {
+		t.Errorf("unexpected result %v", r)
+	}
+
+	// 图标生成在图标目录中，icon_path 指向中尺寸图标
+	database.DB.First(&video, video.ID)
+	for _, path := range utils.IconFiles(video.ID) {
+		if !utils.FileExists(path) {
+			t.Errorf("icon %s not generated", path)
+		}
+	}
+	if filepath.Dir(video.IconPath) != config.IconConfig.Dir {
+		t.Errorf("icon_path = %s, want a file in %s", video.IconPath, config.IconConfig.Dir)
+	}
+	if entries, _ := os.ReadDir(utils.ImageDir(video.ID)); len(entries) != len(utils.ImageProfiles("cover"))+1 {
+		t.Errorf("%d files in image dir, want cover variants and source only", len(entries))
+	}
+
+	if w := requestImage(&video, "cover@2x"); w.Code != http.StatusOK || w.Header().Get("Cache-Control") == "no-cache" {
+		t.Errorf("generated variant: status %d, cache %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if w := requestImage(&video, "icon-small"); w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("icon: status %d, type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 后台任务类型
+const (
+	JobTypeScan       = "scan"
+	JobTypeCover      = "cover"
+	JobTypeIcon       = "icon"
+	JobTypeClean      = "clean"
+	JobTypeOptimize   = "optimize"
+	JobTypeSprite     = "sprite"
+	JobTypePreview    = "preview"
+	JobTypeImages     = "images"
+	JobTypeScene      = "scene"
+	JobTypeClip       = "clip"
+	JobTypeCandidates = "candidates"
+)
+
+// RegisterJobs 注册后台任务处理函数
+func RegisterJobs() {
+	jobs.Register(JobTypeScan, runScanLibrary)
+	jobs.Register(JobTypeCover, runGenerateCovers)
+	jobs.Register(JobTypeIcon, runGenerateIcon)
+	jobs.Register(JobTypeClean, runCleanInvalidIndex)
+	jobs.Register(JobTypeOptimize, runOptimizeVideos)
+	jobs.Register(JobTypeSprite, runGenerateSprites)
+	jobs.Register(JobTypePreview, runGeneratePreviews)
+	jobs.Register(JobTypeImages, runRegenerateImages)
+	jobs.Register(JobTypeScene, runDetectScenes)
+	jobs.Register(JobTypeClip, runExportClip)
+	jobs.Register(JobTypeCandidates, runGenerateCoverCandidates)
+}
+
+// enqueueJob 创建后台任务并返回任务ID
+func enqueueJob(c *gin.Context, jobType string, libraryID uint, payload interface{}, message string) {
+	job, err := jobs.Enqueue(jobType, libraryID, payload)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建任务失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": message,
+		"job_id":  job.ID,
+		"job":     job,
+	})
+}
+
+// enqueuePendingJob 在后台创建任务（不返回响应），同一视频库的同类任务已在排队或执行时不重复创建
+func enqueuePendingJob(jobType string, libraryID uint, payload interface{}) error {
+	var pending int64
+	database.DB.Model(&models.Job{}).
+		Where("type = ? AND library_id = ? AND status IN ?", jobType, libraryID, []string{jobs.StatusQueued, jobs.StatusRunning}).
+		Count(&pending)
+	if pending > 0 {
+		return nil
+	}
+	_, err := jobs.Enqueue(jobType, libraryID, payload)
+	return err
+}
+
+// GetJobs 获取任务列表
+func GetJobs(c *gin.Context) {
+	query := database.DB.Model(&models.Job{})
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+	if libraryID := c.Query("library_id"); libraryID != "" {
+		query = query.Where("library_id = ?", libraryID)
+	}
+
+	limit := 50
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 && l <= 500 {
+		limit = l
+	}
+
+	var list []models.Job
+	if err := query.Order("id DESC").Limit(limit).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取任务列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetJob 获取任务详情
+func GetJob(c *gin.Context) {
+	id := c.Param("id")
+	var job models.Job
+
+	if err := database.DB.First(&job, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob 取消任务
+func CancelJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	switch err := jobs.Cancel(uint(id)); err {
+	case nil:
+		c.JSON(http.StatusOK, gin.H{"message": "任务已取消"})
+	case jobs.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
+
+// GetJobEvents 通过 SSE 推送任务进度，不带 ID 时推送全部任务
+func GetJobEvents(c *gin.Context) {
+	var jobID uint
+	if idParam := c.Param("id"); idParam != "" {
+		id, err := strconv.ParseUint(idParam, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+			return
+		}
+		jobID = uint(id)
+	}
+
+	// 先订阅再查询状态，避免错过查询期间结束的任务
+	events, unsubscribe := jobs.Subscribe(jobID)
+	defer unsubscribe()
+
+	if jobID != 0 {
+		var job models.Job
+		if err := database.DB.First(&job, jobID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+			return
+		}
+
+		// 任务已结束时直接返回最终状态
+		if job.Status != jobs.StatusQueued && job.Status != jobs.StatusRunning {
+			c.SSEvent("progress", jobs.Progress{
+				JobID:     job.ID,
+				Type:      job.Type,
+				Status:    job.Status,
+				LastError: job.Error,
+			})
+			return
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		case p := <-events:
+			c.SSEvent("progress", p)
+			// 单个任务结束后关闭连接
+			if jobID != 0 && p.Status != jobs.StatusRunning {
+				return false
+			}
+			return true
+		}
+	})
+}
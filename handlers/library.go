@@ -1,14 +1,19 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 	"hidevideo/backend/config"
 	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
 	"hidevideo/backend/models"
+	"hidevideo/backend/scheduler"
 	"hidevideo/backend/utils"
+	"hidevideo/backend/watcher"
 
 	"github.com/gin-gonic/gin"
 )
@@ -116,36 +121,23 @@ func DeleteLibrary(c *gin.Context) {
 		return
 	}
 
-	// 删除视频库的封面文件
+	// 删除视频库的全部视频（包括文件丢失后被软删除的视频）及其封面、生成的文件、字幕、章节、媒体信息、标签和评论
 	var videos []models.Video
-	database.DB.Where("library_id = ?", id).Find(&videos)
-	for _, video := range videos {
-		if video.CoverPath != "" {
-			utils.FileExists(video.CoverPath)
-		}
+	database.DB.Unscoped().Where("library_id = ?", id).Find(&videos)
+	for i := range videos {
+		purgeVideo(&videos[i])
 	}
 
-	// 删除视频库（级联删除视频、评论、标签关联）
-	// 先删除视频标签关联
-	database.DB.Where("video_id IN ?", func() []uint {
-		var ids []uint
-		for _, v := range videos {
-			ids = append(ids, v.ID)
-		}
-		return ids
-	}()).Delete(&models.VideoTag{})
-
-	// 删除评论
-	database.DB.Where("video_id IN ?", func() []uint {
-		var ids []uint
-		for _, v := range videos {
-			ids = append(ids, v.ID)
-		}
-		return ids
-	}()).Delete(&models.Comment{})
+	// 停止目录监听
+	watcher.Unwatch(library.ID)
 
-	// 删除视频
-	database.DB.Where("library_id = ?", id).Delete(&models.Video{})
+	// 删除该库的定时计划
+	var schedules []models.Schedule
+	database.DB.Where("library_id = ?", library.ID).Find(&schedules)
+	for _, s := range schedules {
+		scheduler.Remove(s.ID)
+		database.DB.Delete(&s)
+	}
 
 	// 删除视频库
 	database.DB.Delete(&library)
@@ -153,77 +145,126 @@ func DeleteLibrary(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
 
-// ScanLibrary 扫描视频库
-func ScanLibrary(c *gin.Context) {
+// UpdateLibrarySettings 更新视频库扫描设置
+func UpdateLibrarySettings(c *gin.Context) {
 	id := c.Param("id")
-	var library models.VideoLibrary
+	var req struct {
+		ExcludePatterns []string `json:"exclude_patterns"`
+		MinFileSize     int64    `json:"min_file_size"`
+		MinDuration     float64  `json:"min_duration"`
+		FollowSymlinks  bool     `json:"follow_symlinks"`
+		ExtraExtensions []string `json:"extra_extensions"`
+		SceneThreshold  float64  `json:"scene_threshold"`
+	}
 
-	if err := database.DB.First(&library, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
 		return
 	}
 
-	var videos []string
-	var err error
-
-	// 本地库扫描
-	videos, err = utils.GetVideoFiles(library.Path)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("扫描失败: %v", err)})
+	if req.MinFileSize < 0 || req.MinDuration < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "最小文件大小和最小时长不能为负数"})
 		return
 	}
 
-	var addedCount int
-	var skipCount int
+	if req.SceneThreshold < 0 || req.SceneThreshold >= 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "场景检测阈值应在 0~1 之间"})
+		return
+	}
 
-	for _, videoPath := range videos {
-		// 检查视频是否已存在
-		var count int64
-		database.DB.Model(&models.Video{}).Where("filepath = ?", videoPath).Count(&count)
-		if count > 0 {
-			skipCount++
-			continue
-		}
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
 
-		var duration float64
-		var width, height int
-		var codec string
+	// 校验规则是否有效
+	if _, err := utils.NewScanFilter(utils.ScanOptions{ExcludePatterns: req.ExcludePatterns}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		// 获取视频信息
-		videoInfo, err := utils.GetVideoInfo(videoPath)
-		if err != nil {
+	var patterns []string
+	for _, p := range req.ExcludePatterns {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	var extensions []string
+	for _, ext := range req.ExtraExtensions {
+		if ext = utils.NormalizeExtension(ext); ext == "" {
 			continue
 		}
-		duration = videoInfo.Duration
-		width = videoInfo.Width
-		height = videoInfo.Height
-		codec = videoInfo.Codec
-
-		video := models.Video{
-			LibraryID: library.ID,
-			Filename:  filepath.Base(videoPath),
-			Filepath:  videoPath,
-			Duration:  duration,
-			Width:     width,
-			Height:    height,
-			Codec:     codec,
+		if !utils.IsExtraVideoExtension(ext) && !utils.IsVideoFile(ext) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的扩展名: " + ext})
+			return
 		}
+		extensions = append(extensions, ext)
+	}
 
-		if err := database.DB.Create(&video).Error; err != nil {
-			continue
+	database.DB.Model(&library).Updates(map[string]interface{}{
+		"exclude_patterns": strings.Join(patterns, "\n"),
+		"min_file_size":    req.MinFileSize,
+		"min_duration":     req.MinDuration,
+		"follow_symlinks":  req.FollowSymlinks,
+		"extra_extensions": strings.Join(extensions, ","),
+		"scene_threshold":  req.SceneThreshold,
+	})
+	database.DB.First(&library, library.ID)
+
+	// 监听中的视频库按新规则重新监听
+	if library.Watch {
+		if err := watchLibrary(library); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "重新监听失败: " + err.Error()})
+			return
 		}
-		addedCount++
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "扫描完成",
-		"added":       addedCount,
-		"skipped":     skipCount,
-		"total_found": len(videos),
+		"message": "设置成功",
+		"library": library,
 	})
 }
 
-// GenerateCovers 生成视频封面
+// libraryScanFilter 根据视频库设置生成扫描过滤器
+func libraryScanFilter(library models.VideoLibrary) (*utils.ScanFilter, error) {
+	opts := utils.ScanOptions{
+		MinFileSize:    library.MinFileSize,
+		FollowSymlinks: library.FollowSymlinks,
+	}
+	for _, p := range strings.Split(library.ExcludePatterns, "\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			opts.ExcludePatterns = append(opts.ExcludePatterns, p)
+		}
+	}
+	for _, ext := range strings.Split(library.ExtraExtensions, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			opts.ExtraExtensions = append(opts.ExtraExtensions, ext)
+		}
+	}
+	return utils.NewScanFilter(opts)
+}
+
+// ScanLibrary 扫描视频库（创建后台任务）
+func ScanLibrary(c *gin.Context) {
+	id := c.Param("id")
+	var library models.VideoLibrary
+
+	if err := database.DB.First(&library, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	enqueueJob(c, JobTypeScan, library.ID, nil, "扫描任务已创建")
+}
+
+// coverJobPayload 封面生成任务参数
+type coverJobPayload struct {
+	Second float64 `json:"second"`
+	Mode   string  `json:"mode"`
+}
+
+// GenerateCovers 生成视频封面（创建后台任务）
 func GenerateCovers(c *gin.Context) {
 	id := c.Param("id")
 	var req struct {
@@ -247,60 +288,105 @@ func GenerateCovers(c *gin.Context) {
 		return
 	}
 
+	enqueueJob(c, JobTypeCover, library.ID, coverJobPayload{Second: req.Second, Mode: req.Mode}, "封面生成任务已创建")
+}
+
+// runGenerateCovers 执行封面生成任务
+func runGenerateCovers(t *jobs.Task) (interface{}, error) {
+	var req coverJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
 	var videos []models.Video
 	if req.Mode == "new" {
 		// 仅生成没有封面的视频（检查cover_path是否为空或NULL）
-		database.DB.Where("library_id = ? AND (cover_path IS NULL OR cover_path = '' OR LENGTH(cover_path) = 0)", id).Find(&videos)
+		database.DB.Where("library_id = ? AND (cover_path IS NULL OR cover_path = '' OR LENGTH(cover_path) = 0)", t.Job.LibraryID).Find(&videos)
 	} else {
 		// 全部重置
-		database.DB.Where("library_id = ?", id).Find(&videos)
+		database.DB.Where("library_id = ?", t.Job.LibraryID).Find(&videos)
 	}
 
 	var successCount int
 	var failCount int
+	// 指定时间点为空白画面、改用其他画面的视频
+	fallbacks := []gin.H{}
+	// 找不到非空白画面的视频
+	blanks := []gin.H{}
 
+	t.SetTotal(len(videos))
 	for _, video := range videos {
-		coverPath, err := utils.GenerateCover(video.Filepath, video.ID, req.Second)
+		if t.Cancelled() {
+			break
+		}
+
+		result, err := utils.GenerateAutoCover(video.Filepath, video.ID, req.Second, video.Duration)
 		if err != nil {
 			failCount++
+			t.Step(video.Filepath, err)
 			continue
 		}
 
+		if result.Blank {
+			blanks = append(blanks, gin.H{"video_id": video.ID, "filename": video.Filename})
+		} else if result.Fallback {
+			fallbacks = append(fallbacks, gin.H{"video_id": video.ID, "filename": video.Filename, "second": result.Second})
+		}
+
 		// 更新视频的封面路径（使用相对路径）
-		relativePath := coverPath
+		relativePath := result.Path
 		database.DB.Model(&video).Update("cover_path", relativePath)
+		video.CoverPath = relativePath
+		onCoverChanged(&video)
 		successCount++
+		t.Step(video.Filepath, nil)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "封面生成完成",
-		"success": successCount,
-		"failed":  failCount,
-		"total":   len(videos),
-	})
+	return gin.H{
+		"success":   successCount,
+		"failed":    failCount,
+		"total":     len(videos),
+		"fallbacks": fallbacks,
+		"blank":     blanks,
+	}, nil
 }
 
-// CleanInvalidIndex 清除错误索引
+// CleanInvalidIndex 清除错误索引（创建后台任务）
 func CleanInvalidIndex(c *gin.Context) {
+	enqueueJob(c, JobTypeClean, 0, nil, "清理任务已创建")
+}
+
+// runCleanInvalidIndex 执行清除错误索引任务
+func runCleanInvalidIndex(t *jobs.Task) (interface{}, error) {
 	var videos []models.Video
 	database.DB.Find(&videos)
 
 	var deletedVideos int
 	var deletedCovers int
 	var deletedOrphanCovers int
+	var deletedIcons int
+	var deletedOrphanIcons int
 	var deletedLibraries int
+	var purgedVideos int
 
 	// 遍历所有视频，检查文件是否存在
+	t.SetTotal(len(videos))
 	for _, video := range videos {
+		if t.Cancelled() {
+			return nil, t.Ctx.Err()
+		}
+
 		// 检查视频文件是否存在
 		if _, err := os.Stat(video.Filepath); os.IsNotExist(err) {
-			// 删除视频相关的评论
-			database.DB.Where("video_id = ?", video.ID).Delete(&models.Comment{})
-			// 删除视频标签关联
-			database.DB.Where("video_id = ?", video.ID).Delete(&models.VideoTag{})
+			// 只删除可以重新生成的文件和媒体信息，评论、标签、章节、字幕和封面保留在软删除的记录上，
+			// 文件被移动后再次扫描到时按内容指纹恢复
+			removeGeneratedFiles(&video)
+			deleteMediaInfo(database.DB, video.ID)
+			database.DB.Model(&video).Updates(map[string]interface{}{"preview_path": "", "icon_path": ""})
 			// 删除视频
 			database.DB.Delete(&video)
 			deletedVideos++
+			t.Step(video.Filepath, nil)
 			continue
 		}
 
@@ -312,11 +398,34 @@ func CleanInvalidIndex(c *gin.Context) {
 				deletedCovers++
 			}
 		}
+
+		// 检查图标是否存在（旧版本保存的是图标目录，同样清除）
+		if video.IconPath != "" {
+			if info, err := os.Stat(video.IconPath); err != nil || info.IsDir() {
+				database.DB.Model(&video).Update("icon_path", "")
+				deletedIcons++
+			}
+		}
+		t.Step(video.Filepath, nil)
+	}
+
+	// 文件丢失超过保留时间仍未恢复的视频彻底删除
+	var expired []models.Video
+	database.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-config.CleanConfig.DeletedRetention)).Find(&expired)
+	for i := range expired {
+		purgeVideo(&expired[i])
+		purgedVideos++
 	}
 
 	// 查询数据库中所有视频的封面路径
 	var coverPaths []string
-	database.DB.Model(&models.Video{}).Where("cover_path != ?", "").Pluck("cover_path", &coverPaths)
+	// 包括软删除的视频，文件恢复后继续使用原来的封面
+	database.DB.Unscoped().Model(&models.Video{}).Where("cover_path != ?", "").Pluck("cover_path", &coverPaths)
+
+	// 悬停预览与封面保存在同一目录
+	var previewPaths []string
+	database.DB.Model(&models.Video{}).Where("preview_path != ?", "").Pluck("preview_path", &previewPaths)
+	coverPaths = append(coverPaths, previewPaths...)
 
 	// 创建map方便快速查找
 	coverPathMap := make(map[string]bool)
@@ -329,7 +438,8 @@ func CleanInvalidIndex(c *gin.Context) {
 	entries, err := os.ReadDir(coverDir)
 	if err == nil {
 		for _, entry := range entries {
-			if entry.IsDir() {
+			// 只清理自动生成的封面和预览，跳过正在生成的临时文件和正在上传的图片
+			if entry.IsDir() || !generatedCoverPattern.MatchString(entry.Name()) || !orphanExpired(entry) {
 				continue
 			}
 			// 获取文件完整路径
@@ -343,6 +453,25 @@ func CleanInvalidIndex(c *gin.Context) {
 		}
 	}
 
+	// 清理没有视频引用的图标
+	var iconVideoIDs []uint
+	database.DB.Model(&models.Video{}).Where("icon_path != ?", "").Pluck("id", &iconVideoIDs)
+	iconPathMap := make(map[string]bool)
+	for _, id := range iconVideoIDs {
+		for _, path := range utils.IconFiles(id) {
+			iconPathMap[path] = true
+		}
+	}
+	if entries, err := os.ReadDir(config.IconConfig.Dir); err == nil {
+		for _, entry := range entries {
+			iconFilePath := filepath.Join(config.IconConfig.Dir, entry.Name())
+			if !entry.IsDir() && generatedIconPattern.MatchString(entry.Name()) && orphanExpired(entry) && !iconPathMap[iconFilePath] {
+				os.Remove(iconFilePath)
+				deletedOrphanIcons++
+			}
+		}
+	}
+
 	// 清理已删除的视频库（软删除的库）
 	// 使用 Unscoped 查询包括软删除在内的所有记录
 	var deletedLibs []models.VideoLibrary
@@ -359,11 +488,26 @@ func CleanInvalidIndex(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":                "清理完成",
-		"deleted_videos":         deletedVideos,
-		"deleted_covers":         deletedCovers,
-		"deleted_orphan_covers":  deletedOrphanCovers,
-		"deleted_libraries":      deletedLibraries,
-	})
+	return gin.H{
+		"deleted_videos":        deletedVideos,
+		"deleted_covers":        deletedCovers,
+		"deleted_orphan_covers": deletedOrphanCovers,
+		"deleted_icons":         deletedIcons,
+		"deleted_orphan_icons":  deletedOrphanIcons,
+		"deleted_libraries":     deletedLibraries,
+		"purged_videos":         purgedVideos,
+	}, nil
+}
+
+// 清理任务只删除这些格式的文件：自动截取和上传后缩放的封面、悬停预览、图标，
+// 生成中的临时文件（包含 .tmp）和上传中的原图（upload_ 开头）不匹配
+var (
+	generatedCoverPattern = regexp.MustCompile(`^(cover_\d+_u?\d+\.jpg|preview_\d+\.(mp4|webp))$`)
+	generatedIconPattern  = regexp.MustCompile(`^icon_\d+_[^.]+\.[a-z]+$`)
+)
+
+// orphanExpired 文件修改时间超过宽限期，刚生成还未写入数据库的文件不删除
+func orphanExpired(entry os.DirEntry) bool {
+	info, err := entry.Info()
+	return err == nil && time.Since(info.ModTime()) > config.CleanConfig.OrphanGrace
 }
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runClean 同步执行清理任务
+func runClean(t *testing.T) gin.H {
+	t.Helper()
+	result, err := runCleanInvalidIndex(&jobs.Task{Ctx: context.Background(), Job: &models.Job{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.(gin.H)
+}
+
+// countRows 统计视频的关联记录（包括软删除的记录）
+func countRows(videoID uint, model interface{}) int64 {
+	var n int64
+	database.DB.Unscoped().Model(model).Where("video_id = ?", videoID).Count(&n)
+	return n
+}
+
+func TestCleanPurgesExpiredVideos(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{"gone.mp4": "expired movie"})
+	scanLibrary(t, library)
+	video := findVideo(t, filepath.Join(library.Path, "gone.mp4"))
+	database.DB.Create(&models.Comment{VideoID: video.ID, UserID: 1, Content: "old"})
+	os.Remove(video.Filepath)
+
+	// 保留期内只软删除
+	runClean(t)
+	if video = findVideo(t, video.Filepath); !video.DeletedAt.Valid || countRows(video.ID, &models.Comment{}) != 1 {
+		t.Fatal("missing video not kept as soft-deleted")
+	}
+
+	// 超过保留期后彻底删除
+	expired := time.Now().Add(-config.CleanConfig.DeletedRetention - time.Hour)
+	database.DB.Unscoped().Model(&video).Update("deleted_at", expired)
+	runClean(t)
+	var n int64
+	database.DB.Unscoped().Model(&models.Video{}).Where("id = ?", video.ID).Count(&n)
+	if n != 0 || countRows(video.ID, &models.Comment{}) != 0 {
+		t.Errorf("expired video not purged: video rows %d, comments %d", n, countRows(video.ID, &models.Comment{}))
+	}
+}
+
+func TestCleanKeepsFilesInProgress(t *testing.T) {
+	dir := config.ServerConfig.StaticPath
+	old := time.Now().Add(-config.CleanConfig.OrphanGrace - time.Hour)
+	files := map[string]bool{
+		"cover_9001_5.jpg":         true,  // 没有视频引用的旧封面
+		"preview_9001.mp4":         true,  // 没有视频引用的旧预览
+		"cover_9001_6.jpg":         false, // 刚生成，可能还未写入数据库
+		"cover_9001_7.jpg.tmp.jpg": false, // 正在生成
+		"preview_9001.mp4.tmp":     false,
+		"upload_9001_1.png":        false, // 正在上传
+		"notes.txt":                false,
+	}
+	for name := range files {
+		path := filepath.Join(dir, name)
+		writeTestFile(t, path, name)
+		if name != "cover_9001_6.jpg" {
+			os.Chtimes(path, old, old)
+		}
+	}
+
+	runClean(t)
+	for name, removed := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if removed != os.IsNotExist(err) {
+			t.Errorf("%s: removed = %v, want %v", name, os.IsNotExist(err), removed)
+		}
+		os.Remove(filepath.Join(dir, name))
+	}
+}
+
+func TestDeleteLibraryPurgesSoftDeletedVideos(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{"kept.mp4": "kept movie", "gone.mp4": "gone movie"})
+	scanLibrary(t, library)
+	gone := findVideo(t, filepath.Join(library.Path, "gone.mp4"))
+	kept := findVideo(t, filepath.Join(library.Path, "kept.mp4"))
+	os.Remove(gone.Filepath)
+	runClean(t)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: fmt.Sprint(library.ID)}}
+	c.Request = httptest.NewRequest(http.MethodDelete, "/", nil)
+	DeleteLibrary(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+
+	var n int64
+	database.DB.Unscoped().Model(&models.Video{}).Where("library_id = ?", library.ID).Count(&n)
+	if n != 0 {
+		t.Errorf("%d video rows left after deleting the library", n)
+	}
+	for _, id := range []uint{gone.ID, kept.ID} {
+		if streams := countRows(id, &models.VideoStream{}); streams != 0 {
+			t.Errorf("video %d: %d streams left", id, streams)
+		}
+	}
+}
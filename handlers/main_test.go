@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/utils"
+)
+
+// TestMain 使用临时目录中的数据库和数据目录，媒体操作使用 FakeMediaTool，测试不依赖 ffmpeg
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hidevideo-handlers-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	config.DatabaseConfig.Path = filepath.Join(dir, "hidevideo.db")
+	config.ServerConfig.StaticPath = filepath.Join(dir, "covers")
+	config.ServerConfig.UploadPath = dir
+	config.IconConfig.Dir = filepath.Join(dir, "icons")
+	config.ImageConfig.Dir = filepath.Join(dir, "images")
+	config.SpriteConfig.Dir = filepath.Join(dir, "sprites")
+	config.SubtitleConfig.Dir = filepath.Join(dir, "subtitles")
+	config.ChapterConfig.Dir = filepath.Join(dir, "chapters")
+	config.ClipConfig.Dir = filepath.Join(dir, "clips")
+	config.HLSConfig.CacheDir = filepath.Join(dir, "hls")
+	config.TranscodeConfig.CacheDir = filepath.Join(dir, "transcode")
+	os.MkdirAll(config.ServerConfig.StaticPath, 0755)
+
+	utils.Media = &utils.FakeMediaTool{}
+	// 注册任务类型以便创建任务，不启动工作池，任务由测试直接执行
+	RegisterJobs()
+	if err := database.Init(); err != nil {
+		fmt.Println(err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+
+	if db, err := database.DB.DB(); err == nil {
+		db.Close()
+	}
+	os.RemoveAll(dir)
+	// config 包初始化时在当前目录创建的空数据目录
+	os.Remove("data/covers")
+	os.Remove("data")
+	os.Exit(code)
+}
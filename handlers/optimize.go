@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// optimizeJobPayload 预转码任务参数，未指定视频时处理整个视频库
+type optimizeJobPayload struct {
+	VideoIDs []uint `json:"video_ids,omitempty"`
+}
+
+// optimizeCaps 预转码的目标格式，按浏览器默认支持的格式判断
+var optimizeCaps = ClientCaps{
+	Containers:  defaultContainers,
+	VideoCodecs: defaultVideoCodecs,
+	AudioCodecs: defaultAudioCodecs,
+}
+
+// OptimizeLibrary 预转码整个视频库（创建后台任务）
+func OptimizeLibrary(c *gin.Context) {
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	enqueueJob(c, JobTypeOptimize, library.ID, nil, "预转码任务已创建")
+}
+
+// OptimizeVideos 预转码选中的视频（创建后台任务）
+func OptimizeVideos(c *gin.Context) {
+	var req struct {
+		VideoIDs []uint `json:"video_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.VideoIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择视频"})
+		return
+	}
+
+	enqueueJob(c, JobTypeOptimize, 0, optimizeJobPayload{VideoIDs: req.VideoIDs}, "预转码任务已创建")
+}
+
+// runOptimizeVideos 执行预转码任务，浏览器可以直接播放的视频会被跳过
+func runOptimizeVideos(t *jobs.Task) (interface{}, error) {
+	var req optimizeJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var videos []models.Video
+	if len(req.VideoIDs) > 0 {
+		database.DB.Where("id IN ?", req.VideoIDs).Find(&videos)
+	} else {
+		database.DB.Where("library_id = ?", t.Job.LibraryID).Find(&videos)
+	}
+
+	var optimizedCount, skippedCount, failCount int
+
+	t.SetTotal(len(videos))
+	for _, video := range videos {
+		if t.Cancelled() {
+			break
+		}
+
+		if _, err := os.Stat(video.Filepath); err != nil {
+			failCount++
+			t.Step(video.Filepath, fmt.Errorf("视频文件不存在"))
+			continue
+		}
+
+		version := video.ModTime.Unix()
+		if _, ok := transcode.Cached(video.ID, version); ok {
+			skippedCount++
+			t.Step(video.Filepath, nil)
+			continue
+		}
+
+		decision := decidePlayback(&video, loadStreams(&video), optimizeCaps, defaultAudio)
+		if decision.Mode == PlayDirect {
+			skippedCount++
+			t.Step(video.Filepath, nil)
+			continue
+		}
+
+		args := append(codecArgs(decision), "-movflags", "+faststart", "-f", "mp4")
+		if _, err := transcode.Optimize(t.Ctx, video.ID, version, video.Filepath, args); err != nil {
+			if t.Cancelled() {
+				break
+			}
+			failCount++
+			t.Step(video.Filepath, err)
+			continue
+		}
+
+		optimizedCount++
+		t.Step(video.Filepath, nil)
+	}
+
+	files, size := transcode.Stats()
+	return gin.H{
+		"optimized":   optimizedCount,
+		"skipped":     skippedCount,
+		"failed":      failCount,
+		"total":       len(videos),
+		"cache_files": files,
+		"cache_size":  size,
+	}, nil
+}
+
+// GetTranscodeCache 获取预转码缓存占用
+func GetTranscodeCache(c *gin.Context) {
+	files, size := transcode.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"files":    files,
+		"size":     size,
+		"max_size": config.TranscodeConfig.CacheSize,
+	})
+}
+
+// ClearTranscodeCache 清理预转码缓存，指定 video_id 时只删除该视频（仅管理员）
+func ClearTranscodeCache(c *gin.Context) {
+	var removed int
+	if videoID := c.Query("video_id"); videoID != "" {
+		id, err := strconv.ParseUint(videoID, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的视频ID"})
+			return
+		}
+		removed = transcode.Purge(uint(id))
+	} else {
+		removed = transcode.Clear()
+	}
+
+	files, size := transcode.Stats()
+	c.JSON(http.StatusOK, gin.H{
+		"message": "清理完成",
+		"removed": removed,
+		"files":   files,
+		"size":    size,
+	})
+}
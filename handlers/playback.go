@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 播放方式
+const (
+	PlayDirect         = "direct"    // 直接输出原文件
+	PlayRemux          = "remux"     // 复制音视频流，重新封装为 fMP4
+	PlayAudioTranscode = "audio"     // 复制视频流，仅转码音频
+	PlayTranscode      = "transcode" // 完整转码
+)
+
+// 客户端未声明时默认支持的格式（主流浏览器，HEVC 沿用之前直接播放的行为）
+var (
+	defaultContainers  = []string{"mp4", "webm", "ogg"}
+	defaultVideoCodecs = []string{"h264", "hevc", "vp8", "vp9", "av1", "theora"}
+	defaultAudioCodecs = []string{"aac", "mp3", "opus", "vorbis", "flac"}
+)
+
+// 可以复制到 MP4 容器中的编码
+var (
+	mp4VideoCodecs = []string{"h264", "hevc", "av1", "vp9"}
+	mp4AudioCodecs = []string{"aac", "mp3", "opus", "flac", "ac3", "eac3", "alac"}
+)
+
+// 内嵌封面等图片流，不作为视频流
+var pictureCodecs = []string{"mjpeg", "png", "bmp", "gif", "webp"}
+
+// ClientCaps 客户端声明支持的容器和编码
+type ClientCaps struct {
+	Containers  []string
+	VideoCodecs []string
+	AudioCodecs []string
+}
+
+// PlaybackDecision 播放方式判断结果
+type PlaybackDecision struct {
+	Mode         string `json:"mode"`
+	Reason       string `json:"reason"`
+	Container    string `json:"container"`
+	VideoCodec   string `json:"video_codec"`
+	AudioCodec   string `json:"audio_codec"`
+	VideoStream  int    `json:"video_stream"`  // 使用的视频流序号，-1 表示没有
+	AudioStream  int    `json:"audio_stream"`  // 使用的音频流序号，-1 表示没有
+	DefaultAudio bool   `json:"default_audio"` // 使用的是否为默认音轨，非默认音轨不能直接播放原文件
+}
+
+// parseCodecList 解析逗号分隔的格式列表
+func parseCodecList(value string, defaults []string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	if len(list) == 0 {
+		return defaults
+	}
+	return list
+}
+
+// clientCaps 从查询参数读取客户端支持的格式，如 ?video_codecs=h264,hevc&audio_codecs=aac
+func clientCaps(c *gin.Context) ClientCaps {
+	return ClientCaps{
+		Containers:  parseCodecList(c.Query("containers"), defaultContainers),
+		VideoCodecs: parseCodecList(c.Query("video_codecs"), defaultVideoCodecs),
+		AudioCodecs: parseCodecList(c.Query("audio_codecs"), defaultAudioCodecs),
+	}
+}
+
+// containsCodec 列表中是否包含该格式
+func containsCodec(list []string, codec string) bool {
+	for _, item := range list {
+		if item == codec {
+			return true
+		}
+	}
+	return false
+}
+
+// containerName 根据扩展名判断容器格式
+func containerName(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".m4v", ".mov":
+		return "mp4"
+	case ".webm":
+		return "webm"
+	case ".mkv":
+		return "mkv"
+	case ".ogg", ".ogv":
+		return "ogg"
+	}
+	return strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+}
+
+// loadStreams 读取视频的媒体流，旧数据没有流信息时重新探测并保存
+func loadStreams(video *models.Video) []models.VideoStream {
+	var streams []models.VideoStream
+	database.DB.Where("video_id = ?", video.ID).Order("stream_index").Find(&streams)
+	if len(streams) > 0 {
+		return streams
+	}
+
+	data, err := utils.Media.Probe(video.Filepath)
+	if err != nil {
+		return nil
+	}
+	replaceMediaInfo(video.ID, data)
+	return data.StreamRecords()
+}
+
+// selectStreams 选择播放使用的视频流和音频流，音频依次使用指定的音轨、偏好语言的音轨和默认音轨
+func selectStreams(streams []models.VideoStream, audio AudioChoice) (*models.VideoStream, *models.VideoStream) {
+	var videoStream, audioStream, chosen *models.VideoStream
+	for i := range streams {
+		s := &streams[i]
+		switch s.Type {
+		case "video":
+			if videoStream == nil && !s.AttachedPic && !containsCodec(pictureCodecs, s.Codec) {
+				videoStream = s
+			}
+		case "audio":
+			if audioStream == nil || (s.Default && !audioStream.Default) {
+				audioStream = s
+			}
+			if audio.Stream >= 0 {
+				if s.StreamIndex == audio.Stream {
+					chosen = s
+				}
+			} else if chosen == nil && audio.Language != "" && languageMatches(s.Language, audio.Language) {
+				chosen = s
+			}
+		}
+	}
+	if chosen != nil {
+		audioStream = chosen
+	}
+	return videoStream, audioStream
+}
+
+// decidePlayback 根据媒体流和客户端能力选择播放方式
+func decidePlayback(video *models.Video, streams []models.VideoStream, caps ClientCaps, audio AudioChoice) PlaybackDecision {
+	videoStream, audioStream := selectStreams(streams, audio)
+	_, defaultStream := selectStreams(streams, defaultAudio)
+	d := PlaybackDecision{
+		Container:    containerName(video.Filepath),
+		VideoStream:  -1,
+		AudioStream:  -1,
+		DefaultAudio: audioStream == defaultStream,
+	}
+	if videoStream != nil {
+		d.VideoCodec = videoStream.Codec
+		d.VideoStream = videoStream.StreamIndex
+	} else if len(streams) == 0 {
+		// 无法获取流信息时使用扫描时记录的编码
+		d.VideoCodec = video.Codec
+	}
+	if audioStream != nil {
+		d.AudioCodec = audioStream.Codec
+		d.AudioStream = audioStream.StreamIndex
+	}
+
+	videoOK := d.VideoCodec == "" || containsCodec(caps.VideoCodecs, d.VideoCodec)
+	audioOK := audioStream == nil || containsCodec(caps.AudioCodecs, d.AudioCodec)
+	videoCopy := videoOK && (d.VideoCodec == "" || containsCodec(mp4VideoCodecs, d.VideoCodec))
+	audioCopy := audioOK && (audioStream == nil || containsCodec(mp4AudioCodecs, d.AudioCodec))
+
+	switch {
+	case len(streams) == 0 && d.VideoCodec == "":
+		d.Mode, d.Reason = PlayTranscode, "无法识别媒体流"
+	case videoOK && audioOK && d.DefaultAudio && containsCodec(caps.Containers, d.Container):
+		d.Mode, d.Reason = PlayDirect, "容器和编码均受支持"
+	case videoCopy && audioCopy && len(streams) > 0:
+		d.Mode, d.Reason = PlayRemux, "容器不受支持，编码受支持"
+		if !d.DefaultAudio && containsCodec(caps.Containers, d.Container) {
+			d.Reason = "选择了非默认音轨"
+		}
+	case videoCopy:
+		d.Mode, d.Reason = PlayAudioTranscode, "音频编码不受支持"
+	default:
+		d.Mode, d.Reason = PlayTranscode, "视频编码不受支持"
+	}
+	return d
+}
+
+// playbackArgs 返回边转码边播放的 fMP4 参数
+func playbackArgs(d PlaybackDecision) []string {
+	return append(codecArgs(d),
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4",
+	)
+}
+
+// codecArgs 返回按播放方式选择流和编码的 ffmpeg 参数
+func codecArgs(d PlaybackDecision) []string {
+	var args []string
+	if d.VideoStream >= 0 {
+		args = append(args, "-map", "0:"+strconv.Itoa(d.VideoStream))
+	} else {
+		args = append(args, "-map", "0:v:0?")
+	}
+	if d.AudioStream >= 0 {
+		args = append(args, "-map", "0:"+strconv.Itoa(d.AudioStream))
+	} else {
+		args = append(args, "-map", "0:a:0?")
+	}
+
+	switch d.Mode {
+	case PlayRemux:
+		args = append(args, "-c:v", "copy", "-c:a", "copy")
+	case PlayAudioTranscode:
+		args = append(args, "-c:v", "copy", "-c:a", "aac", "-ac", "2", "-b:a", "192k")
+	default:
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-c:a", "aac", "-ac", "2", "-b:a", "192k")
+	}
+	// Safari 需要 hvc1 标记才能播放 MP4 中的 HEVC
+	if d.Mode != PlayTranscode && d.VideoCodec == "hevc" {
+		args = append(args, "-tag:v", "hvc1")
+	}
+	return args
+}
+
+// GetPlaybackInfo 获取视频的播放方式
+func GetPlaybackInfo(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	streams := loadStreams(&video)
+	c.JSON(http.StatusOK, decidePlayback(&video, streams, clientCaps(c), audioChoice(c)))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+)
+
+// probeStreams 使用 FakeMediaTool 探测文件，返回入库时保存的媒体流
+func probeStreams(t *testing.T, path string) []models.VideoStream {
+	t.Helper()
+	writeTestFile(t, path, "playback")
+	data, err := utils.Media.Probe(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data.StreamRecords()
+}
+
+func TestDecidePlayback(t *testing.T) {
+	dir := t.TempDir()
+	caps := ClientCaps{Containers: defaultContainers, VideoCodecs: defaultVideoCodecs, AudioCodecs: defaultAudioCodecs}
+	streams := probeStreams(t, filepath.Join(dir, "movie.mp4"))
+
+	// 在 FakeMediaTool 的 H.264/AAC 媒体流基础上修改编码或追加音轨
+	withCodec := func(typ, codec string) []models.VideoStream {
+		list := append([]models.VideoStream{}, streams...)
+		for i := range list {
+			if list[i].Type == typ {
+				list[i].Codec = codec
+			}
+		}
+		return list
+	}
+	secondAudio := append(append([]models.VideoStream{}, streams...),
+		models.VideoStream{StreamIndex: 2, Type: "audio", Codec: "aac", Language: "jpn"})
+	// 内嵌封面排在视频流之前
+	coverArt := append([]models.VideoStream{{StreamIndex: 2, Type: "video", Codec: "h264", AttachedPic: true}}, withCodec("video", "mpeg2video")...)
+
+	tests := []struct {
+		name    string
+		path    string
+		streams []models.VideoStream
+		audio   AudioChoice
+		mode    string
+		reason  string
+	}{
+		{"mp4 h264 aac", "movie.mp4", streams, defaultAudio, PlayDirect, "容器和编码均受支持"},
+		{"mkv h264 aac", "movie.mkv", streams, defaultAudio, PlayRemux, "容器不受支持，编码受支持"},
+		{"unsupported audio", "movie.mp4", withCodec("audio", "dts"), defaultAudio, PlayAudioTranscode, "音频编码不受支持"},
+		{"unsupported video", "movie.mp4", withCodec("video", "mpeg2video"), defaultAudio, PlayTranscode, "视频编码不受支持"},
+		{"non-default audio", "movie.mp4", secondAudio, AudioChoice{Stream: 2}, PlayRemux, "选择了非默认音轨"},
+		{"preferred language", "movie.mp4", secondAudio, AudioChoice{Stream: -1, Language: "jpn"}, PlayRemux, "选择了非默认音轨"},
+		{"no streams", "movie.mp4", nil, defaultAudio, PlayTranscode, "无法识别媒体流"},
+		{"attached picture", "movie.mp4", coverArt, defaultAudio, PlayTranscode, "视频编码不受支持"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			video := &models.Video{Filepath: filepath.Join(dir, tt.path)}
+			d := decidePlayback(video, tt.streams, caps, tt.audio)
+			if d.Mode != tt.mode || d.Reason != tt.reason {
+				t.Errorf("decision = %s (%s), want %s (%s)", d.Mode, d.Reason, tt.mode, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewJobPayload 悬停预览生成任务参数
+type previewJobPayload struct {
+	Mode string `json:"mode"` // "new" 仅没有预览的视频, "reset" 全部重新生成
+}
+
+// GeneratePreviews 生成悬停预览片段（创建后台任务）
+func GeneratePreviews(c *gin.Context) {
+	var req previewJobPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
+		}
+	}
+
+	// 默认模式
+	if req.Mode == "" {
+		req.Mode = "new"
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	enqueueJob(c, JobTypePreview, library.ID, req, "预览生成任务已创建")
+}
+
+// runGeneratePreviews 执行悬停预览生成任务
+func runGeneratePreviews(t *jobs.Task) (interface{}, error) {
+	var req previewJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var videos []models.Video
+	if req.Mode == "new" {
+		database.DB.Where("library_id = ? AND (preview_path IS NULL OR preview_path = '')", t.Job.LibraryID).Find(&videos)
+	} else {
+		database.DB.Where("library_id = ?", t.Job.LibraryID).Find(&videos)
+	}
+
+	var successCount int
+	var failCount int
+
+	t.SetTotal(len(videos))
+	for _, video := range videos {
+		if t.Cancelled() {
+			break
+		}
+
+		previewPath, err := utils.GeneratePreview(t.Ctx, video.Filepath, video.ID, video.Duration, func(ctx context.Context, opts utils.TranscodeOptions) error {
+			return transcode.Run(ctx, transcode.Session{Kind: transcode.KindPreview, VideoID: video.ID}, opts)
+		})
+		if err != nil {
+			if t.Cancelled() {
+				break
+			}
+			failCount++
+			t.Step(video.Filepath, err)
+			continue
+		}
+
+		// 切换预览格式后删除旧文件
+		if video.PreviewPath != "" && video.PreviewPath != previewPath {
+			os.Remove(video.PreviewPath)
+		}
+
+		database.DB.Model(&video).Update("preview_path", previewPath)
+		successCount++
+		t.Step(video.Filepath, nil)
+	}
+
+	return gin.H{
+		"success": successCount,
+		"failed":  failCount,
+		"total":   len(videos),
+	}, nil
+}
@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errTooShort 视频时长低于视频库设置的最小时长
+var errTooShort = errors.New("视频时长低于最小时长")
+
+// probeResult 单个文件的探测结果
+type probeResult struct {
+	path string
+	stat *utils.FileStat
+	hash string
+	info *utils.VideoInfo
+	err  error
+
+	subtitles []models.Subtitle // 外挂字幕
+}
+
+// probeFile 获取文件指纹并调用 ffprobe 探测视频信息
+func probeFile(path string) probeResult {
+	r := probeResult{path: path}
+
+	r.stat, r.err = utils.StatFile(path)
+	if r.err != nil {
+		return r
+	}
+	r.hash, _ = utils.PartialHash(path)
+	r.info, r.err = utils.GetVideoInfo(path)
+	return r
+}
+
+// probeFiles 使用有限数量的 worker 并发探测文件，任务取消后停止派发
+func probeFiles(ctx context.Context, paths []string, workers int) <-chan probeResult {
+	if workers < 1 {
+		workers = 1
+	}
+
+	input := make(chan string)
+	output := make(chan probeResult, workers)
+
+	go func() {
+		defer close(input)
+		for _, path := range paths {
+			select {
+			case input <- path:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range input {
+				output <- probeFile(path)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(output)
+	}()
+
+	return output
+}
+
+// runScanLibrary 执行视频库扫描任务，仅重新探测新增或发生变化的文件，
+// 并将新文件与已丢失的视频按内容指纹匹配，识别移动或重命名
+func runScanLibrary(t *jobs.Task) (interface{}, error) {
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, t.Job.LibraryID).Error; err != nil {
+		return nil, fmt.Errorf("视频库不存在")
+	}
+
+	// 扫描期间暂停处理该视频库的监听事件
+	defer lockLibrary(library.ID)()
+
+	filter, err := libraryScanFilter(library)
+	if err != nil {
+		return nil, err
+	}
+
+	// 本地库扫描
+	videos, err := utils.GetVideoFiles(library.Path, filter)
+	if err != nil {
+		return nil, fmt.Errorf("扫描失败: %v", err)
+	}
+
+	// 预先加载该库已有的视频
+	var existingVideos []models.Video
+	database.DB.Where("library_id = ?", library.ID).Find(&existingVideos)
+	existing := make(map[string]models.Video, len(existingVideos))
+	for _, v := range existingVideos {
+		existing[v.Filepath] = v
+	}
+
+	// 预先加载已入库的外挂字幕，同一目录只读取一次
+	var sidecars []models.Subtitle
+	database.DB.Where("source = ? AND video_id IN (?)", utils.SubtitleSidecar,
+		database.DB.Model(&models.Video{}).Select("id").Where("library_id = ?", library.ID)).Find(&sidecars)
+	knownSidecars := make(map[uint][]models.Subtitle)
+	for _, sub := range sidecars {
+		knownSidecars[sub.VideoID] = append(knownSidecars[sub.VideoID], sub)
+	}
+	dirCache := make(map[string][]os.DirEntry)
+
+	// 预先加载全部已入库路径，其他视频库中已存在的同一文件不重复入库
+	var allPaths []string
+	database.DB.Model(&models.Video{}).Pluck("filepath", &allPaths)
+	indexed := make(map[string]bool, len(allPaths))
+	for _, path := range allPaths {
+		indexed[path] = true
+	}
+
+	var addedCount int
+	var updatedCount int
+	var unchangedCount int
+	var movedCount int
+	var excludedCount int
+	var failedCount int
+	var subtitleCount int
+	found := make(map[string]bool, len(videos))
+	var newPaths []string
+
+	t.SetTotal(len(videos))
+
+	// 第一轮：处理已入库的文件，收集新文件
+	for _, videoPath := range videos {
+		if t.Cancelled() {
+			break
+		}
+		found[videoPath] = true
+
+		video, ok := existing[videoPath]
+		if !ok {
+			if indexed[videoPath] {
+				unchangedCount++
+				t.Step(videoPath, nil)
+				continue
+			}
+			newPaths = append(newPaths, videoPath)
+			continue
+		}
+
+		stat, err := utils.StatFile(videoPath)
+		if err != nil {
+			failedCount++
+			t.Step(videoPath, err)
+			continue
+		}
+
+		switch {
+		case video.FileSize == 0 && video.ModTime.IsZero(), fingerprintChanged(video, stat):
+			// 旧版本入库的视频没有指纹和媒体流信息，与已变化的文件一样重新探测
+			if err := reprobeVideo(&video, stat); err != nil {
+				failedCount++
+				t.Step(videoPath, err)
+				continue
+			}
+			updatedCount++
+		default:
+			if video.ContentHash == "" {
+				if hash, err := utils.PartialHash(videoPath); err == nil {
+					database.DB.Model(&video).Update("content_hash", hash)
+				}
+			}
+			unchangedCount++
+		}
+
+		// 外挂字幕可能在视频入库后才添加
+		if syncSidecarSubtitles(video.ID, knownSidecars[video.ID], utils.FindSidecarSubtitles(videoPath, dirCache)) {
+			subtitleCount++
+		}
+		t.Step(videoPath, nil)
+	}
+
+	// 已入库但本次未找到、且磁盘上确实不存在的视频，作为移动匹配的候选
+	var missing []models.Video
+	if !t.Cancelled() {
+		for path, v := range existing {
+			if found[path] {
+				continue
+			}
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				missing = append(missing, v)
+			}
+		}
+
+		// 已被监听或清理任务软删除的视频同样可以恢复
+		var deleted []models.Video
+		database.DB.Unscoped().Where("library_id = ? AND deleted_at IS NOT NULL", library.ID).Find(&deleted)
+		missing = append(missing, deleted...)
+	}
+	moved := make(map[uint]bool)
+
+	// 新视频分批在事务中写入
+	batchSize := config.ScanConfig.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	batch := make([]models.Video, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			return tx.CreateInBatches(batch, len(batch)).Error
+		})
+		if err != nil {
+			failedCount += len(batch)
+		} else {
+			addedCount += len(batch)
+		}
+		batch = batch[:0]
+	}
+
+	// 第二轮：并发探测新文件
+	for r := range probeFiles(t.Ctx, newPaths, config.ScanConfig.ProbeWorkers) {
+		if r.err != nil {
+			failedCount++
+			t.Step(r.path, r.err)
+			continue
+		}
+		if library.MinDuration > 0 && r.info.Duration < library.MinDuration {
+			excludedCount++
+			t.Step(r.path, nil)
+			continue
+		}
+		r.subtitles = utils.FindSidecarSubtitles(r.path, dirCache)
+
+		var candidates []models.Video
+		for _, v := range missing {
+			if !moved[v.ID] {
+				candidates = append(candidates, v)
+			}
+		}
+
+		// 匹配到已丢失的视频时，更新路径并保留原有的标签、演员、评论等信息
+		if match := matchMovedVideo(candidates, r); match != nil {
+			if err := applyMove(match, library.ID, r); err != nil {
+				failedCount++
+				t.Step(r.path, err)
+				continue
+			}
+			moved[match.ID] = true
+			movedCount++
+			t.Step(r.path, nil)
+			continue
+		}
+
+		batch = append(batch, newVideoRecord(library.ID, r))
+		if len(batch) >= batchSize {
+			flush()
+		}
+		t.Step(r.path, nil)
+	}
+	flush()
+
+	// 统计已入库但本次未找到的文件
+	var missingCount int
+	if !t.Cancelled() {
+		for path, v := range existing {
+			if !found[path] && !moved[v.ID] {
+				missingCount++
+			}
+		}
+	}
+
+	return gin.H{
+		"added":       addedCount,
+		"updated":     updatedCount,
+		"unchanged":   unchangedCount,
+		"moved":       movedCount,
+		"missing":     missingCount,
+		"excluded":    excludedCount,
+		"failed":      failedCount,
+		"subtitles":   subtitleCount,
+		"total_found": len(videos),
+	}, nil
+}
+
+// indexNewFile 探测单个新文件并入库，candidates 返回可能被移动到该路径的已丢失视频，
+// 匹配成功时更新其路径并保留原有的标签、演员、评论等信息
+func indexNewFile(library *models.VideoLibrary, videoPath string, candidates func(hash string, size int64) []models.Video) (*models.Video, bool, error) {
+	r := probeFile(videoPath)
+	if r.err != nil {
+		return nil, false, r.err
+	}
+	if library.MinDuration > 0 && r.info.Duration < library.MinDuration {
+		return nil, false, errTooShort
+	}
+	r.subtitles = utils.FindSidecarSubtitles(videoPath, nil)
+
+	if match := matchMovedVideo(candidates(r.hash, r.stat.Size), r); match != nil {
+		if err := applyMove(match, library.ID, r); err != nil {
+			return nil, false, err
+		}
+		return match, true, nil
+	}
+
+	video := newVideoRecord(library.ID, r)
+	if err := database.DB.Create(&video).Error; err != nil {
+		return nil, false, err
+	}
+	return &video, false, nil
+}
+
+// newVideoRecord 根据探测结果生成视频记录（包含媒体流和容器信息）
+func newVideoRecord(libraryID uint, r probeResult) models.Video {
+	video := models.Video{
+		LibraryID:   libraryID,
+		Filename:    filepath.Base(r.path),
+		Filepath:    r.path,
+		Duration:    r.info.Duration,
+		Width:       r.info.Width,
+		Height:      r.info.Height,
+		Codec:       r.info.Codec,
+		FileSize:    r.stat.Size,
+		ModTime:     r.stat.ModTime,
+		Inode:       r.stat.Inode,
+		ContentHash: r.hash,
+	}
+	video.Subtitles = append(video.Subtitles, r.subtitles...)
+	if r.info.Probe != nil {
+		video.Streams = r.info.Probe.StreamRecords()
+		video.Format = r.info.Probe.FormatRecord()
+		video.Subtitles = append(video.Subtitles, r.info.Probe.EmbeddedSubtitles()...)
+		video.Chapters = r.info.Probe.ChapterRecords()
+	}
+	return video
+}
+
+// applyMove 将已丢失的视频指向新路径，已被软删除的记录同时恢复，外挂字幕按新路径重新关联并重新写入媒体信息
+func applyMove(match *models.Video, libraryID uint, r probeResult) error {
+	updates := fingerprintUpdates(r.stat)
+	updates["filepath"] = r.path
+	updates["filename"] = filepath.Base(r.path)
+	updates["content_hash"] = r.hash
+	updates["library_id"] = libraryID
+	updates["deleted_at"] = nil
+	if err := database.DB.Unscoped().Model(match).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	var known []models.Subtitle
+	database.DB.Where("video_id = ? AND source = ?", match.ID, utils.SubtitleSidecar).Find(&known)
+	syncSidecarSubtitles(match.ID, known, r.subtitles)
+
+	// 清理任务会删除已丢失视频的媒体信息，恢复时重新写入
+	return replaceMediaInfo(match.ID, r.info.Probe)
+}
+
+// matchMovedVideo 在已丢失的视频中查找与新文件内容一致的记录
+// 有内容指纹时按指纹、大小和时长匹配；旧记录没有指纹时退化为按文件名、大小和时长匹配
+func matchMovedVideo(missing []models.Video, r probeResult) *models.Video {
+	for i := range missing {
+		v := &missing[i]
+		if v.FileSize != r.stat.Size {
+			continue
+		}
+		if math.Abs(v.Duration-r.info.Duration) > 1 {
+			continue
+		}
+		if v.ContentHash != "" {
+			if r.hash != "" && v.ContentHash == r.hash {
+				return v
+			}
+			continue
+		}
+		if v.Filename == filepath.Base(r.path) {
+			return v
+		}
+	}
+	return nil
+}
+
+// fingerprintChanged 判断文件指纹是否与数据库记录不一致
+func fingerprintChanged(video models.Video, stat *utils.FileStat) bool {
+	if video.FileSize != stat.Size || !video.ModTime.Equal(stat.ModTime) {
+		return true
+	}
+	return video.Inode != 0 && stat.Inode != 0 && video.Inode != stat.Inode
+}
+
+// fingerprintUpdates 生成更新文件指纹的字段
+func fingerprintUpdates(stat *utils.FileStat) map[string]interface{} {
+	return map[string]interface{}{
+		"file_size": stat.Size,
+		"mod_time":  stat.ModTime,
+		"inode":     stat.Inode,
+	}
+}
+
+// reprobeVideo 重新探测已变化的视频文件并更新信息
+func reprobeVideo(video *models.Video, stat *utils.FileStat) error {
+	videoInfo, err := utils.GetVideoInfo(video.Filepath)
+	if err != nil {
+		return err
+	}
+
+	updates := fingerprintUpdates(stat)
+	if hash, err := utils.PartialHash(video.Filepath); err == nil {
+		updates["content_hash"] = hash
+	}
+	updates["duration"] = videoInfo.Duration
+	updates["width"] = videoInfo.Width
+	updates["height"] = videoInfo.Height
+	updates["codec"] = videoInfo.Codec
+
+	if err := database.DB.Model(video).Updates(updates).Error; err != nil {
+		return err
+	}
+	return replaceMediaInfo(video.ID, videoInfo.Probe)
+}
+
+// replaceMediaInfo 用新的探测结果替换视频的媒体流、容器信息、内嵌字幕和内嵌章节
+func replaceMediaInfo(videoID uint, data *utils.ProbeData) error {
+	if data == nil {
+		return nil
+	}
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := deleteMediaInfo(tx, videoID); err != nil {
+			return err
+		}
+
+		// 文件已变化，之前提取的内嵌字幕同时作废
+		if err := tx.Where("video_id = ? AND source = ?", videoID, utils.SubtitleEmbedded).Delete(&models.Subtitle{}).Error; err != nil {
+			return err
+		}
+		if files, err := filepath.Glob(filepath.Join(utils.SubtitleDir(videoID), "embedded_*")); err == nil {
+			for _, f := range files {
+				os.Remove(f)
+			}
+		}
+		subtitles := data.EmbeddedSubtitles()
+		for i := range subtitles {
+			subtitles[i].VideoID = videoID
+		}
+		if len(subtitles) > 0 {
+			if err := tx.Create(&subtitles).Error; err != nil {
+				return err
+			}
+		}
+
+		// 章节缩略图同样作废，之后按需重新截取
+		utils.RemoveChapterFiles(videoID)
+		if err := replaceEmbeddedChapters(tx, videoID, data); err != nil {
+			return err
+		}
+
+		streams := data.StreamRecords()
+		for i := range streams {
+			streams[i].VideoID = videoID
+		}
+		if len(streams) > 0 {
+			if err := tx.Create(&streams).Error; err != nil {
+				return err
+			}
+		}
+
+		format := data.FormatRecord()
+		format.VideoID = videoID
+		return tx.Create(format).Error
+	})
+}
+
+// deleteMediaInfo 删除视频的媒体流和容器信息
+func deleteMediaInfo(tx *gorm.DB, videoID uint) error {
+	if err := tx.Where("video_id = ?", videoID).Delete(&models.VideoStream{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("video_id = ?", videoID).Delete(&models.VideoFormat{}).Error
+}
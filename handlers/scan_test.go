@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestLibrary 在临时目录中创建视频库，files 为相对路径到文件内容的映射
+func newTestLibrary(t *testing.T, files map[string]string) *models.VideoLibrary {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		writeTestFile(t, filepath.Join(dir, name), content)
+	}
+
+	library := models.VideoLibrary{Name: t.Name(), Path: dir}
+	if err := database.DB.Create(&library).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &library
+}
+
+// writeTestFile 写入测试文件，自动创建所在目录
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// scanLibrary 同步执行扫描任务并返回统计结果
+func scanLibrary(t *testing.T, library *models.VideoLibrary) gin.H {
+	t.Helper()
+	result, err := runScanLibrary(&jobs.Task{Ctx: context.Background(), Job: &models.Job{LibraryID: library.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.(gin.H)
+}
+
+// expectCounts 检查扫描结果中的统计数量
+func expectCounts(t *testing.T, result gin.H, want map[string]int) {
+	t.Helper()
+	for key, n := range want {
+		if result[key] != n {
+			t.Errorf("%s = %v, want %d (result %v)", key, result[key], n, result)
+		}
+	}
+}
+
+// findVideo 按路径查找视频（包括软删除的记录）
+func findVideo(t *testing.T, path string) models.Video {
+	t.Helper()
+	var video models.Video
+	if err := database.DB.Unscoped().Where("filepath = ?", path).First(&video).Error; err != nil {
+		t.Fatalf("video %s not indexed: %v", path, err)
+	}
+	return video
+}
+
+func TestScanLibraryIncremental(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{
+		"a.mp4":     "video a",
+		"sub/b.mkv": "video b",
+		"notes.txt": "not a video",
+		"sub/c.avi": "video c",
+	})
+
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 3, "updated": 0, "unchanged": 0, "total_found": 3})
+
+	video := findVideo(t, filepath.Join(library.Path, "a.mp4"))
+	if video.Duration != 120 || video.Width != 1920 || video.Height != 1080 || video.Codec != "h264" {
+		t.Errorf("unexpected media info: %+v", video)
+	}
+	if video.ContentHash == "" || video.FileSize != int64(len("video a")) {
+		t.Errorf("fingerprint not recorded: hash=%q size=%d", video.ContentHash, video.FileSize)
+	}
+	var streams int64
+	database.DB.Model(&models.VideoStream{}).Where("video_id = ?", video.ID).Count(&streams)
+	if streams != 2 {
+		t.Errorf("streams = %d, want 2", streams)
+	}
+
+	// 未变化的文件不重新探测
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 0, "updated": 0, "unchanged": 3})
+
+	// 文件内容变化后重新探测
+	writeTestFile(t, filepath.Join(library.Path, "a.mp4"), "video a, edited")
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 0, "updated": 1, "unchanged": 2})
+	if video = findVideo(t, video.Filepath); video.FileSize != int64(len("video a, edited")) {
+		t.Errorf("file size = %d after rescan", video.FileSize)
+	}
+}
+
+func TestScanLibraryDetectsMove(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{"old/movie.mp4": "moved movie"})
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 1})
+
+	oldPath := filepath.Join(library.Path, "old", "movie.mp4")
+	video := findVideo(t, oldPath)
+	tag := models.Tag{Name: t.Name()}
+	database.DB.Create(&tag)
+	database.DB.Create(&models.VideoTag{VideoID: video.ID, TagID: tag.ID})
+
+	newPath := filepath.Join(library.Path, "new", "renamed.mp4")
+	os.MkdirAll(filepath.Dir(newPath), 0755)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		t.Fatal(err)
+	}
+
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 0, "moved": 1, "missing": 0})
+	moved := findVideo(t, newPath)
+	if moved.ID != video.ID || moved.Filename != "renamed.mp4" {
+		t.Errorf("moved video = %d %s, want id %d", moved.ID, moved.Filename, video.ID)
+	}
+	var tags int64
+	database.DB.Model(&models.VideoTag{}).Where("video_id = ? AND tag_id = ?", video.ID, tag.ID).Count(&tags)
+	if tags != 1 {
+		t.Error("tag lost after move")
+	}
+}
+
+func TestScanLibraryRestoresCleanedVideo(t *testing.T) {
+	library := newTestLibrary(t, map[string]string{"movie.mkv": "cleaned movie"})
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 1})
+
+	oldPath := filepath.Join(library.Path, "movie.mkv")
+	video := findVideo(t, oldPath)
+	comment := models.Comment{VideoID: video.ID, UserID: 1, Content: "keep me"}
+	database.DB.Create(&comment)
+
+	// 文件移出视频库后运行清理任务，视频被软删除
+	outside := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.Rename(oldPath, outside); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCleanInvalidIndex(&jobs.Task{Ctx: context.Background(), Job: &models.Job{}}); err != nil {
+		t.Fatal(err)
+	}
+	if video = findVideo(t, oldPath); !video.DeletedAt.Valid {
+		t.Fatal("missing video not soft-deleted by clean job")
+	}
+
+	// 文件移回视频库的其他目录后恢复原记录
+	newPath := filepath.Join(library.Path, "back", "movie.mkv")
+	os.MkdirAll(filepath.Dir(newPath), 0755)
+	if err := os.Rename(outside, newPath); err != nil {
+		t.Fatal(err)
+	}
+	expectCounts(t, scanLibrary(t, library), map[string]int{"added": 0, "moved": 1})
+
+	restored := findVideo(t, newPath)
+	if restored.ID != video.ID || restored.DeletedAt.Valid {
+		t.Fatalf("video %d not restored (got id %d, deleted %v)", video.ID, restored.ID, restored.DeletedAt.Valid)
+	}
+	var comments, streams int64
+	database.DB.Model(&models.Comment{}).Where("video_id = ?", video.ID).Count(&comments)
+	database.DB.Model(&models.VideoStream{}).Where("video_id = ?", video.ID).Count(&streams)
+	if comments != 1 {
+		t.Error("comment lost after clean and rescan")
+	}
+	if streams != 2 {
+		t.Errorf("streams = %d after restore, want 2", streams)
+	}
+}
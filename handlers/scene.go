@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// sceneJobPayload 场景检测任务参数
+type sceneJobPayload struct {
+	Mode     string `json:"mode"`                // "new" 仅处理没有章节的视频, "reset" 重新生成场景章节
+	VideoIDs []uint `json:"video_ids,omitempty"` // 为空时处理整个视频库
+}
+
+// sceneThreshold 视频库的场景检测阈值，未设置时使用默认值
+func sceneThreshold(library *models.VideoLibrary) float64 {
+	if library != nil && library.SceneThreshold > 0 {
+		return library.SceneThreshold
+	}
+	return config.SceneConfig.Threshold
+}
+
+// DetectLibraryScenes 检测视频库中视频的场景变化并生成章节（创建后台任务）
+func DetectLibraryScenes(c *gin.Context) {
+	var req sceneJobPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
+		}
+	}
+
+	// 默认模式
+	if req.Mode == "" {
+		req.Mode = "new"
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	enqueueJob(c, JobTypeScene, library.ID, sceneJobPayload{Mode: req.Mode}, "场景检测任务已创建")
+}
+
+// DetectVideoScenes 检测单个视频的场景变化并重新生成章节（创建后台任务）
+func DetectVideoScenes(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	payload := sceneJobPayload{Mode: "reset", VideoIDs: []uint{video.ID}}
+	enqueueJob(c, JobTypeScene, video.LibraryID, payload, "场景检测任务已创建")
+}
+
+// runDetectScenes 执行场景检测任务
+func runDetectScenes(t *jobs.Task) (interface{}, error) {
+	var req sceneJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var videos []models.Video
+	if len(req.VideoIDs) > 0 {
+		database.DB.Preload("Library").Where("id IN ?", req.VideoIDs).Find(&videos)
+	} else {
+		database.DB.Preload("Library").Where("library_id = ?", t.Job.LibraryID).Find(&videos)
+	}
+
+	var successCount, skippedCount, failCount, chapterCount int
+
+	t.SetTotal(len(videos))
+	for _, video := range videos {
+		if t.Cancelled() {
+			break
+		}
+
+		// 仅处理没有章节的视频
+		if req.Mode == "new" {
+			var count int64
+			database.DB.Model(&models.Chapter{}).Where("video_id = ?", video.ID).Count(&count)
+			if count > 0 {
+				skippedCount++
+				t.Step(video.Filepath, nil)
+				continue
+			}
+		}
+
+		if _, err := os.Stat(video.Filepath); err != nil {
+			failCount++
+			t.Step(video.Filepath, fmt.Errorf("视频文件不存在"))
+			continue
+		}
+
+		n, err := detectVideoScenes(t.Ctx, &video, sceneThreshold(&video.Library))
+		if err != nil {
+			if t.Cancelled() {
+				break
+			}
+			failCount++
+			t.Step(video.Filepath, err)
+			continue
+		}
+		chapterCount += n
+		successCount++
+		t.Step(video.Filepath, nil)
+	}
+
+	return gin.H{
+		"success":  successCount,
+		"skipped":  skippedCount,
+		"failed":   failCount,
+		"total":    len(videos),
+		"chapters": chapterCount,
+	}, nil
+}
+
+// detectVideoScenes 检测视频的场景变化，替换之前自动生成的章节并截取每个章节起点的缩略图，返回章节数
+func detectVideoScenes(ctx context.Context, video *models.Video, threshold float64) (int, error) {
+	var log bytes.Buffer
+	err := transcode.Run(ctx, transcode.Session{Kind: transcode.KindScene, VideoID: video.ID, Mode: fmt.Sprintf("%.2f", threshold)}, utils.TranscodeOptions{
+		Input:  video.Filepath,
+		Args:   utils.SceneDetectArgs(threshold),
+		Stderr: &log,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	times, err := utils.ParseSceneTimes(log.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	starts := utils.SceneBoundaries(times, video.Duration, config.SceneConfig.MinInterval, config.SceneConfig.MaxScenes)
+
+	var old []models.Chapter
+	database.DB.Where("video_id = ? AND source = ?", video.ID, utils.ChapterScene).Find(&old)
+	for i := range old {
+		removeChapterThumbnail(&old[i])
+	}
+
+	chapters := make([]models.Chapter, 0, len(starts))
+	for i, start := range starts {
+		chapter := models.Chapter{
+			VideoID: video.ID,
+			Source:  utils.ChapterScene,
+			Title:   fmt.Sprintf("场景 %d", i+1),
+			Start:   start,
+		}
+		// 缩略图截取失败时仍保留章节，请求缩略图时再重试
+		if path, err := utils.GenerateChapterThumbnail(video.Filepath, video.ID, start); err == nil {
+			chapter.ThumbnailPath = path
+		}
+		chapters = append(chapters, chapter)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("video_id = ? AND source = ?", video.ID, utils.ChapterScene).Delete(&models.Chapter{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&chapters).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(chapters), nil
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+	"hidevideo/backend/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleInfo 计划信息（附带下次执行时间）
+type ScheduleInfo struct {
+	models.Schedule
+	NextRunAt *time.Time `json:"next_run_at"`
+}
+
+// GetSchedules 获取定时计划列表
+func GetSchedules(c *gin.Context) {
+	query := database.DB.Preload("LastJob").Order("id ASC")
+	if libraryID := c.Query("library_id"); libraryID != "" {
+		query = query.Where("library_id = ?", libraryID)
+	}
+
+	var schedules []models.Schedule
+	if err := query.Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取定时计划失败"})
+		return
+	}
+
+	list := make([]ScheduleInfo, len(schedules))
+	for i, s := range schedules {
+		list[i] = ScheduleInfo{Schedule: s, NextRunAt: scheduler.Next(s.ID)}
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// AddSchedule 添加定时计划
+func AddSchedule(c *gin.Context) {
+	var req struct {
+		LibraryID uint    `json:"library_id"`
+		Task      string  `json:"task" binding:"required"`
+		Cron      string  `json:"cron" binding:"required"`
+		Second    float64 `json:"second"` // 封面截图秒数，仅封面任务使用
+		Mode      string  `json:"mode"`   // 封面/缩略图/预览/图片/场景检测模式，默认仅新视频
+		Enabled   *bool   `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入任务类型和执行时间"})
+		return
+	}
+
+	if err := scheduler.Parse(req.Cron); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 cron 表达式: " + err.Error()})
+		return
+	}
+
+	schedule := models.Schedule{
+		LibraryID: req.LibraryID,
+		Task:      req.Task,
+		Cron:      req.Cron,
+		Enabled:   req.Enabled == nil || *req.Enabled,
+	}
+
+	switch req.Task {
+	case JobTypeScan, JobTypeIcon, JobTypeOptimize:
+	case JobTypeCover:
+		if req.Second <= 0 {
+			req.Second = 5
+		}
+		if req.Mode == "" {
+			req.Mode = "new"
+		}
+		payload, _ := json.Marshal(coverJobPayload{Second: req.Second, Mode: req.Mode})
+		schedule.Payload = models.JSONText(payload)
+	case JobTypeSprite:
+		if req.Mode == "" {
+			req.Mode = "new"
+		}
+		payload, _ := json.Marshal(spriteJobPayload{Mode: req.Mode})
+		schedule.Payload = models.JSONText(payload)
+	case JobTypePreview:
+		if req.Mode == "" {
+			req.Mode = "new"
+		}
+		payload, _ := json.Marshal(previewJobPayload{Mode: req.Mode})
+		schedule.Payload = models.JSONText(payload)
+	case JobTypeImages:
+		if req.Mode == "" {
+			req.Mode = "new"
+		}
+		payload, _ := json.Marshal(imageJobPayload{Mode: req.Mode})
+		schedule.Payload = models.JSONText(payload)
+	case JobTypeScene:
+		if req.Mode == "" {
+			req.Mode = "new"
+		}
+		payload, _ := json.Marshal(sceneJobPayload{Mode: req.Mode})
+		schedule.Payload = models.JSONText(payload)
+	case JobTypeClean:
+		// 清理任务作用于全部视频库
+		schedule.LibraryID = 0
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的任务类型"})
+		return
+	}
+
+	if req.Task != JobTypeClean {
+		var library models.VideoLibrary
+		if err := database.DB.First(&library, schedule.LibraryID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+			return
+		}
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "添加定时计划失败"})
+		return
+	}
+
+	if err := scheduler.Reload(schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册定时计划失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "添加成功",
+		"schedule": ScheduleInfo{Schedule: schedule, NextRunAt: scheduler.Next(schedule.ID)},
+	})
+}
+
+// SetScheduleEnabled 启用或禁用定时计划
+func SetScheduleEnabled(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	var schedule models.Schedule
+	if err := database.DB.First(&schedule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "定时计划不存在"})
+		return
+	}
+
+	database.DB.Model(&schedule).Update("enabled", req.Enabled)
+	schedule.Enabled = req.Enabled
+	if err := scheduler.Reload(schedule); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册定时计划失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设置成功",
+		"enabled": req.Enabled,
+	})
+}
+
+// DeleteSchedule 删除定时计划
+func DeleteSchedule(c *gin.Context) {
+	id := c.Param("id")
+
+	var schedule models.Schedule
+	if err := database.DB.First(&schedule, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "定时计划不存在"})
+		return
+	}
+
+	scheduler.Remove(schedule.ID)
+	database.DB.Delete(&schedule)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// spriteJobPayload 拖动预览缩略图任务参数
+type spriteJobPayload struct {
+	Mode string `json:"mode"` // "new" 仅没有缩略图的视频, "reset" 全部重新生成
+}
+
+// spriteURL 将缩略图 VTT 文件路径转换为 URL 路径
+func spriteURL(path string) string {
+	if path == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(config.SpriteConfig.Dir, path)
+	if err != nil {
+		return ""
+	}
+	return "/sprites/" + filepath.ToSlash(rel)
+}
+
+// GenerateSprites 生成拖动预览缩略图（创建后台任务）
+func GenerateSprites(c *gin.Context) {
+	var req spriteJobPayload
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+			return
+		}
+	}
+
+	// 默认模式
+	if req.Mode == "" {
+		req.Mode = "new"
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	enqueueJob(c, JobTypeSprite, library.ID, req, "缩略图生成任务已创建")
+}
+
+// runGenerateSprites 执行拖动预览缩略图生成任务
+func runGenerateSprites(t *jobs.Task) (interface{}, error) {
+	var req spriteJobPayload
+	if err := t.Decode(&req); err != nil {
+		return nil, err
+	}
+
+	var videos []models.Video
+	if req.Mode == "new" {
+		database.DB.Where("library_id = ? AND (thumbnails_path IS NULL OR thumbnails_path = '')", t.Job.LibraryID).Find(&videos)
+	} else {
+		database.DB.Where("library_id = ?", t.Job.LibraryID).Find(&videos)
+	}
+
+	var successCount int
+	var failCount int
+
+	t.SetTotal(len(videos))
+	for _, video := range videos {
+		if t.Cancelled() {
+			break
+		}
+
+		var vttPath string
+		err := transcode.Do(t.Ctx, transcode.Session{Kind: transcode.KindFrames, VideoID: video.ID, Mode: "sprites"}, func(ctx context.Context) error {
+			var err error
+			vttPath, err = utils.GenerateSprites(ctx, video.Filepath, video.ID, video.Duration)
+			return err
+		})
+		if err != nil {
+			failCount++
+			t.Step(video.Filepath, err)
+			continue
+		}
+
+		database.DB.Model(&video).Update("thumbnails_path", vttPath)
+		successCount++
+		t.Step(video.Filepath, nil)
+	}
+
+	return gin.H{
+		"success": successCount,
+		"failed":  failCount,
+		"total":   len(videos),
+	}, nil
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// subtitleItem 转换为响应格式
+func subtitleItem(sub models.Subtitle) gin.H {
+	return gin.H{
+		"id":           sub.ID,
+		"source":       sub.Source,
+		"format":       sub.Format,
+		"language":     sub.Language,
+		"title":        sub.Title,
+		"default":      sub.Default,
+		"forced":       sub.Forced,
+		"stream_index": sub.StreamIndex,
+		"supported":    sub.Source != utils.SubtitleEmbedded || utils.IsTextSubtitle(sub.Format),
+		"url":          fmt.Sprintf("/api/videos/%d/subtitles/%d", sub.VideoID, sub.ID),
+	}
+}
+
+// syncSidecarSubtitles 按本次找到的外挂字幕更新数据库记录，返回是否有变化
+func syncSidecarSubtitles(videoID uint, known, found []models.Subtitle) bool {
+	foundPaths := make(map[string]bool, len(found))
+	for _, sub := range found {
+		foundPaths[sub.Path] = true
+	}
+	knownPaths := make(map[string]bool, len(known))
+	changed := false
+	for _, sub := range known {
+		knownPaths[sub.Path] = true
+		if !foundPaths[sub.Path] {
+			database.DB.Delete(&sub)
+			changed = true
+		}
+	}
+	for _, sub := range found {
+		if !knownPaths[sub.Path] {
+			sub.VideoID = videoID
+			database.DB.Create(&sub)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// deleteSubtitles 删除视频的全部字幕记录和上传、提取的字幕文件
+func deleteSubtitles(videoID uint) {
+	database.DB.Where("video_id = ?", videoID).Delete(&models.Subtitle{})
+	utils.RemoveSubtitleFiles(videoID)
+}
+
+// GetSubtitles 获取视频的字幕列表
+func GetSubtitles(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	var subtitles []models.Subtitle
+	database.DB.Where("video_id = ?", video.ID).Order("id ASC").Find(&subtitles)
+
+	list := make([]gin.H, 0, len(subtitles))
+	for _, sub := range subtitles {
+		list = append(list, subtitleItem(sub))
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetSubtitle 以 WebVTT 格式输出字幕，SRT/ASS 字幕即时转换，内嵌字幕首次请求时提取并缓存
+func GetSubtitle(c *gin.Context) {
+	var sub models.Subtitle
+	if err := database.DB.Where("video_id = ?", c.Param("id")).First(&sub, c.Param("sid")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "字幕不存在"})
+		return
+	}
+
+	if sub.Source == utils.SubtitleEmbedded {
+		if !utils.IsTextSubtitle(sub.Format) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "图形字幕无法转换为 WebVTT"})
+			return
+		}
+		var video models.Video
+		if err := database.DB.First(&video, sub.VideoID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+			return
+		}
+		session := transcodeSession(c, transcode.KindSubtitle, video.ID, "")
+		path, err := utils.ExtractSubtitle(c.Request.Context(), video.Filepath, video.ID, sub.StreamIndex, func(ctx context.Context, opts utils.TranscodeOptions) error {
+			return transcode.Run(ctx, session, opts)
+		})
+		if err == transcode.ErrBusy {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "字幕提取失败: " + err.Error()})
+			return
+		}
+		c.Header("Content-Type", "text/vtt; charset=utf-8")
+		c.File(path)
+		return
+	}
+
+	data, err := os.ReadFile(sub.Path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "字幕文件不存在"})
+		return
+	}
+	vtt, err := utils.ToWebVTT(data, sub.Format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "字幕转换失败: " + err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", vtt)
+}
+
+// UploadSubtitle 上传字幕文件（srt、ass、ssa、vtt）
+func UploadSubtitle(c *gin.Context) {
+	var video models.Video
+	if err := database.DB.First(&video, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择字幕文件"})
+		return
+	}
+	format := utils.SubtitleFormat(file.Filename)
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的字幕格式"})
+		return
+	}
+	if file.Size > config.SubtitleConfig.MaxUploadSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "字幕文件过大"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取字幕文件失败"})
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取字幕文件失败"})
+		return
+	}
+
+	// 保存前先确认能转换为 WebVTT
+	if _, err := utils.ToWebVTT(data, format); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法识别的字幕文件: " + err.Error()})
+		return
+	}
+
+	if err := os.MkdirAll(utils.SubtitleDir(video.ID), 0755); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存字幕失败"})
+		return
+	}
+	path := filepath.Join(utils.SubtitleDir(video.ID), fmt.Sprintf("upload_%d.%s", time.Now().UnixNano(), format))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存字幕失败"})
+		return
+	}
+
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = strings.TrimSuffix(file.Filename, filepath.Ext(file.Filename))
+	}
+	sub := models.Subtitle{
+		VideoID:  video.ID,
+		Source:   utils.SubtitleUpload,
+		Path:     path,
+		Format:   format,
+		Language: strings.ToLower(strings.TrimSpace(c.PostForm("language"))),
+		Title:    title,
+	}
+	if err := database.DB.Create(&sub).Error; err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存字幕失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, subtitleItem(sub))
+}
+
+// DeleteSubtitle 删除上传的字幕，外挂字幕和内嵌字幕不能删除
+func DeleteSubtitle(c *gin.Context) {
+	var sub models.Subtitle
+	if err := database.DB.Where("video_id = ?", c.Param("id")).First(&sub, c.Param("sid")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "字幕不存在"})
+		return
+	}
+	if sub.Source != utils.SubtitleUpload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "只能删除上传的字幕"})
+		return
+	}
+
+	database.DB.Delete(&sub)
+	os.Remove(sub.Path)
+	c.JSON(http.StatusOK, gin.H{"message": "字幕已删除"})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/transcode"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// transcodeSession 构造带有客户端信息的转码会话
+func transcodeSession(c *gin.Context, kind string, videoID uint, mode string) transcode.Session {
+	s := transcode.Session{
+		Kind:    kind,
+		VideoID: videoID,
+		Mode:    mode,
+		Client:  c.ClientIP(),
+	}
+	if username := sessions.Default(c).Get("username"); username != nil {
+		s.User = fmt.Sprint(username)
+	}
+	return s
+}
+
+// GetTranscodeSessions 获取正在运行的转码会话（仅管理员）
+func GetTranscodeSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":       transcode.List(),
+		"max_sessions":   config.TranscodeConfig.MaxSessions,
+		"max_background": config.TranscodeConfig.MaxBackground,
+	})
+}
+
+// StopTranscodeSession 终止转码会话，不指定 ID 时终止全部（仅管理员）
+func StopTranscodeSession(c *gin.Context) {
+	idParam := c.Param("id")
+	if idParam == "" {
+		count := transcode.StopAll()
+		c.JSON(http.StatusOK, gin.H{"message": "已终止全部转码会话", "stopped": count})
+		return
+	}
+
+	id, err := strconv.ParseUint(idParam, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	if !transcode.Stop(id) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "转码会话不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已终止转码会话", "stopped": 1})
+}
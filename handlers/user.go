@@ -6,6 +6,7 @@ import (
 	"hidevideo/backend/models"
 	"time"
 	"strconv"
+	"strings"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -380,10 +381,42 @@ func GetCurrentUser(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := database.DB.Select("id, username, role, created_at").First(&user, userID).Error; err != nil {
+	if err := database.DB.Select("id, username, role, audio_language, created_at").First(&user, userID).Error; err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
+
+// UpdateUserPreferences 修改当前用户的播放偏好（偏好的音轨语言，如 zh、en、jpn，为空表示使用默认音轨）
+func UpdateUserPreferences(c *gin.Context) {
+	session := sessions.Default(c)
+	userID := session.Get("user_id")
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "请先登录"})
+		return
+	}
+
+	var req struct {
+		AudioLanguage string `json:"audio_language"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "参数错误"})
+		return
+	}
+
+	language := strings.ToLower(strings.TrimSpace(req.AudioLanguage))
+	if len(language) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的语言代码"})
+		return
+	}
+
+	if err := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("audio_language", language).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新播放偏好失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "播放偏好已更新", "audio_language": language})
+}
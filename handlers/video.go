@@ -4,7 +4,6 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
 	"sort"
@@ -12,7 +11,9 @@ import (
 	"strings"
 
 	"hidevideo/backend/database"
+	"hidevideo/backend/hls"
 	"hidevideo/backend/models"
+	"hidevideo/backend/transcode"
 	"hidevideo/backend/utils"
 
 	"github.com/gin-gonic/gin"
@@ -56,9 +57,22 @@ func StreamVideo(c *gin.Context) {
 		return
 	}
 
-	codec := getVideoCodec(video.Filepath)
-	if shouldTranscode(ext, codec) {
-		streamTranscodedVideo(c, video.Filepath)
+	decision := decidePlayback(&video, loadStreams(&video), clientCaps(c), audioChoice(c))
+
+	// 已预转码的文件直接输出，支持 Range 请求（预转码文件只包含默认音轨）
+	if decision.DefaultAudio {
+		if cached, ok := transcode.Cached(video.ID, video.ModTime.Unix()); ok {
+			c.Header("X-Playback-Mode", "cached")
+			c.Header("Content-Type", "video/mp4")
+			c.Header("Content-Disposition", "inline")
+			c.File(cached)
+			return
+		}
+	}
+
+	c.Header("X-Playback-Mode", decision.Mode)
+	if decision.Mode != PlayDirect {
+		streamTranscodedVideo(c, &video, decision.Mode, playbackArgs(decision))
 		return
 	}
 
@@ -67,53 +81,31 @@ func StreamVideo(c *gin.Context) {
 	c.File(video.Filepath)
 }
 
-func shouldTranscode(ext, codec string) bool {
-	switch ext {
-	case ".mp4":
-		// h264 和 h265(hevc) 不需要转码
-		return codec != "" && codec != "h264" && codec != "hevc"
-	case ".webm", ".ogg":
-		return false
-	default:
-		return true
-	}
-}
-
-func getVideoCodec(videoPath string) string {
-	cmd := exec.Command("ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=codec_name",
-		"-of", "default=nw=1:nk=1",
-		videoPath,
-	)
-	output, err := cmd.Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(output))
-}
-
-func streamTranscodedVideo(c *gin.Context, videoPath string) {
+// streamTranscodedVideo 将 ffmpeg 输出的 fMP4 直接写入响应
+func streamTranscodedVideo(c *gin.Context, video *models.Video, mode string, args []string) {
 	c.Header("Content-Type", "video/mp4")
 	c.Header("Content-Disposition", "inline")
 	c.Header("Cache-Control", "no-store")
 	c.Status(http.StatusOK)
 
-	cmd := exec.Command("ffmpeg",
-		"-i", videoPath,
-		"-vcodec", "libx264",
-		"-acodec", "aac",
-		"-movflags", "frag_keyframe+empty_moov",
-		"-f", "mp4",
-		"pipe:1",
-	)
-	cmd.Stdout = c.Writer
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "视频转码失败"})
+	// 客户端断开时 ffmpeg 随请求上下文一起终止
+	err := transcode.Run(c.Request.Context(), transcodeSession(c, transcode.KindStream, video.ID, mode), utils.TranscodeOptions{
+		Input:  video.Filepath,
+		Args:   args,
+		Stdout: c.Writer,
+		Stderr: os.Stderr,
+	})
+	if err == nil || c.Writer.Written() {
 		return
 	}
+
+	c.Header("Content-Type", "")
+	c.Header("Cache-Control", "")
+	if err == transcode.ErrBusy {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "视频转码失败"})
 }
 
 // VideoQueryParams 视频查询参数
@@ -358,6 +350,11 @@ func GetVideos(c *gin.Context) {
 			// 转换为 URL 路径
 			videos[i].CoverPath = "/covers/" + getCoverFilename(videos[i].CoverPath)
 		}
+		if videos[i].PreviewPath != "" {
+			videos[i].PreviewPath = "/covers/" + getCoverFilename(videos[i].PreviewPath)
+		}
+		videos[i].ThumbnailsPath = spriteURL(videos[i].ThumbnailsPath)
+		videos[i].IconPath = iconURL(videos[i].IconPath)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -387,7 +384,11 @@ func GetVideo(c *gin.Context) {
 	id := c.Param("id")
 	var video models.Video
 
-	if err := database.DB.Preload("Tags").Preload("Comments").First(&video, id).Error; err != nil {
+	if err := database.DB.Preload("Tags").Preload("Comments").
+		Preload("Streams", func(db *gorm.DB) *gorm.DB { return db.Order("stream_index ASC") }).
+		Preload("Format").
+		Preload("Subtitles").
+		First(&video, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "视频不存在"})
 		return
 	}
@@ -396,6 +397,11 @@ func GetVideo(c *gin.Context) {
 	if video.CoverPath != "" {
 		video.CoverPath = "/covers/" + getCoverFilename(video.CoverPath)
 	}
+	if video.PreviewPath != "" {
+		video.PreviewPath = "/covers/" + getCoverFilename(video.PreviewPath)
+	}
+	video.ThumbnailsPath = spriteURL(video.ThumbnailsPath)
+	video.IconPath = iconURL(video.IconPath)
 
 	c.JSON(http.StatusOK, video)
 }
@@ -549,12 +555,20 @@ func DeleteVideo(c *gin.Context) {
 		}
 	}
 
+	// 删除预览、缩略图等生成的文件
+	removeGeneratedFiles(&video)
+	deleteSubtitles(video.ID)
+	deleteChapters(video.ID)
+
 	// 删除视频标签关联
 	database.DB.Where("video_id = ?", video.ID).Delete(&models.VideoTag{})
 
 	// 删除评论
 	database.DB.Where("video_id = ?", video.ID).Delete(&models.Comment{})
 
+	// 删除媒体信息
+	deleteMediaInfo(database.DB, video.ID)
+
 	// 删除视频记录
 	if err := database.DB.Delete(&video).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除视频记录失败"})
@@ -564,6 +578,36 @@ func DeleteVideo(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "视频删除成功"})
 }
 
+// removeGeneratedFiles 删除视频生成的悬停预览、拖动预览缩略图、候选封面、图片规格、图标、章节缩略图、
+// HLS 切片和预转码缓存，这些文件都可以重新生成
+func removeGeneratedFiles(video *models.Video) {
+	if video.PreviewPath != "" {
+		os.Remove(video.PreviewPath)
+	}
+	utils.RemoveSprites(video.ID)
+	utils.RemoveCoverCandidates(video.ID)
+	utils.RemoveImageVariants(video.ID)
+	utils.RemoveIcons(video.ID)
+	utils.RemoveChapterFiles(video.ID)
+	hls.Purge(video.ID)
+	transcode.Purge(video.ID)
+}
+
+// purgeVideo 彻底删除视频记录（包括已软删除的记录）及其封面、生成的文件、字幕、章节、媒体信息、标签、演员和评论，不删除视频文件
+func purgeVideo(video *models.Video) {
+	if video.CoverPath != "" {
+		os.Remove(video.CoverPath)
+	}
+	removeGeneratedFiles(video)
+	deleteSubtitles(video.ID)
+	deleteChapters(video.ID)
+	deleteMediaInfo(database.DB, video.ID)
+	database.DB.Where("video_id = ?", video.ID).Delete(&models.VideoTag{})
+	database.DB.Where("video_id = ?", video.ID).Delete(&models.VideoActor{})
+	database.DB.Unscoped().Where("video_id = ?", video.ID).Delete(&models.Comment{})
+	database.DB.Unscoped().Delete(video)
+}
+
 // OldFolderInfo 旧版文件夹信息（用于GetFolderTree兼容）
 type OldFolderInfo struct {
 	Name      string            `json:"name"`
@@ -734,6 +778,7 @@ func GetVideoByPath(c *gin.Context) {
 
 	// 获取视频信息
 	videoInfo, err := utils.GetVideoInfo(filepath)
+	stat, statErr := utils.StatFile(filepath)
 	if err != nil {
 		// 如果无法获取视频信息，使用默认值创建
 		video = models.Video{
@@ -750,9 +795,21 @@ func GetVideoByPath(c *gin.Context) {
 			Width:     videoInfo.Width,
 			Height:    videoInfo.Height,
 			Codec:     videoInfo.Codec,
+			Streams:   videoInfo.Probe.StreamRecords(),
+			Format:    videoInfo.Probe.FormatRecord(),
 		}
 	}
 
+	// 记录文件指纹，便于后续增量扫描
+	if statErr == nil {
+		video.FileSize = stat.Size
+		video.ModTime = stat.ModTime
+		video.Inode = stat.Inode
+	}
+	if hash, err := utils.PartialHash(filepath); err == nil {
+		video.ContentHash = hash
+	}
+
 	// 保存到数据库
 	if err := database.DB.Create(&video).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建视频记录失败"})
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+	"hidevideo/backend/utils"
+	"hidevideo/backend/watcher"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartWatchers 为开启监听的视频库启动目录监听
+func StartWatchers() {
+	watcher.SetHandler(handleWatchEvent)
+
+	var libraries []models.VideoLibrary
+	database.DB.Where("watch = ?", true).Find(&libraries)
+	for _, lib := range libraries {
+		if err := watchLibrary(lib); err != nil {
+			log.Printf("监听视频库 %s 失败: %v", lib.Name, err)
+		}
+	}
+}
+
+// SetLibraryWatch 开启或关闭视频库目录监听
+func SetLibraryWatch(c *gin.Context) {
+	id := c.Param("id")
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "视频库不存在"})
+		return
+	}
+
+	if req.Enabled {
+		if err := watchLibrary(library); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "开启监听失败: " + err.Error()})
+			return
+		}
+	} else {
+		watcher.Unwatch(library.ID)
+	}
+
+	database.DB.Model(&library).Update("watch", req.Enabled)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "设置成功",
+		"enabled": req.Enabled,
+	})
+}
+
+// watchLibrary 按视频库的扫描设置开始监听
+func watchLibrary(library models.VideoLibrary) error {
+	filter, err := libraryScanFilter(library)
+	if err != nil {
+		return err
+	}
+	return watcher.Watch(library.ID, library.Path, filter)
+}
+
+// libraryLocks 视频库索引锁，扫描任务、目录监听和片段导出入库时互斥，避免同一文件重复入库
+var (
+	libraryLocks   = make(map[uint]*sync.Mutex)
+	libraryLocksMu sync.Mutex
+)
+
+// lockLibrary 获取视频库的索引锁，返回解锁函数
+func lockLibrary(libraryID uint) func() {
+	libraryLocksMu.Lock()
+	l, ok := libraryLocks[libraryID]
+	if !ok {
+		l = &sync.Mutex{}
+		libraryLocks[libraryID] = l
+	}
+	libraryLocksMu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// handleWatchEvent 处理监听到的文件变化，与同一视频库的扫描任务互斥
+func handleWatchEvent(ev watcher.Event) {
+	defer lockLibrary(ev.LibraryID)()
+
+	if ev.Removed {
+		markRemovedVideos(ev.LibraryID, ev.Path)
+		return
+	}
+
+	// 已入库的文件：内容变化时重新探测
+	var video models.Video
+	if err := database.DB.Where("filepath = ?", ev.Path).First(&video).Error; err == nil {
+		stat, err := utils.StatFile(ev.Path)
+		if err != nil {
+			return
+		}
+		if fingerprintChanged(video, stat) {
+			if err := reprobeVideo(&video, stat); err != nil {
+				log.Printf("重新探测视频失败 %s: %v", ev.Path, err)
+			}
+		}
+		return
+	}
+
+	var library models.VideoLibrary
+	if err := database.DB.First(&library, ev.LibraryID).Error; err != nil {
+		return
+	}
+
+	// 新文件：优先匹配被移走的视频
+	if _, _, err := indexNewFile(&library, ev.Path, func(hash string, size int64) []models.Video {
+		return findMovedCandidates(ev.LibraryID, hash, size)
+	}); err != nil && err != errTooShort {
+		log.Printf("索引视频失败 %s: %v", ev.Path, err)
+	}
+}
+
+// markRemovedVideos 将已删除文件（或已删除目录下的文件）对应的视频标记为删除
+// 使用软删除保留标签、演员、评论等信息，文件重新出现时可按内容指纹恢复
+func markRemovedVideos(libraryID uint, path string) {
+	sep := string(filepath.Separator)
+	prefix := escapeLike(strings.TrimSuffix(path, sep)+sep) + "%"
+
+	var videos []models.Video
+	database.DB.Where(`library_id = ? AND (filepath = ? OR filepath LIKE ? ESCAPE '\')`, libraryID, path, prefix).Find(&videos)
+
+	for _, video := range videos {
+		// 重命名事件可能对应仍存在的路径，再次确认文件已不存在
+		if _, err := os.Stat(video.Filepath); os.IsNotExist(err) {
+			database.DB.Delete(&video)
+		}
+	}
+}
+
+// likeEscaper 转义 LIKE 模式中的通配符，配合 ESCAPE '\' 使用
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike 将路径转换为按字面匹配的 LIKE 模式
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// findMovedCandidates 查询可能被移动的视频：已标记删除或文件已不存在的同指纹记录
+func findMovedCandidates(libraryID uint, hash string, size int64) []models.Video {
+	var videos []models.Video
+	database.DB.Unscoped().
+		Where("library_id = ? AND file_size = ? AND (content_hash = ? OR content_hash = '' OR content_hash IS NULL)", libraryID, size, hash).
+		Find(&videos)
+
+	var candidates []models.Video
+	for _, v := range videos {
+		if v.DeletedAt.Valid {
+			candidates = append(candidates, v)
+			continue
+		}
+		if _, err := os.Stat(v.Filepath); os.IsNotExist(err) {
+			candidates = append(candidates, v)
+		}
+	}
+	return candidates
+}
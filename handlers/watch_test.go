@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+)
+
+func TestMarkRemovedVideosMatchesLiteralPath(t *testing.T) {
+	library := newTestLibrary(t, nil)
+
+	// 目录名中的 _ 和 % 按字面匹配，不会误删相似目录下的视频
+	removed := filepath.Join(library.Path, "a_b%")
+	paths := []string{
+		filepath.Join(removed, "x.mp4"),
+		filepath.Join(library.Path, "aXbY", "y.mp4"),
+		filepath.Join(library.Path, "a_b%c", "z.mp4"),
+	}
+	for _, path := range paths {
+		database.DB.Create(&models.Video{LibraryID: library.ID, Filepath: path, Filename: filepath.Base(path)})
+	}
+
+	markRemovedVideos(library.ID, removed)
+
+	for i, path := range paths {
+		var count int64
+		database.DB.Model(&models.Video{}).Where("filepath = ?", path).Count(&count)
+		want := int64(1)
+		if i == 0 {
+			want = 0
+		}
+		if count != want {
+			t.Errorf("%s: %d rows left, want %d", path, count, want)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package hls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"hidevideo/backend/cache"
+	"hidevideo/backend/config"
+	"hidevideo/backend/transcode"
+	"hidevideo/backend/utils"
+)
+
+// SegmentTimeout 单个分片的最长转码时间
+var SegmentTimeout = 2 * time.Minute
+
+// ErrSegmentRange 分片序号超出视频时长
+var ErrSegmentRange = errors.New("分片不存在")
+
+// Source 需要切片的视频
+type Source struct {
+	VideoID  uint
+	Path     string
+	Version  int64 // 文件修改时间，文件变化后旧的切片不再使用
+	Duration float64
+	Width    int
+	Height   int
+
+	VideoStream int  // 使用的视频流序号，-1 表示第一个不是内嵌封面的视频流
+	AudioStream int  // 使用的音频流序号，-1 表示第一个音轨
+	Audio       bool // 是否有音轨，决定播放列表中声明的编码
+}
+
+// Rendition 一个清晰度的输出参数
+type Rendition struct {
+	Name         string
+	Width        int
+	Height       int
+	VideoBitrate int // kbps
+	AudioBitrate int // kbps
+}
+
+// call 正在转码的分片
+type call struct {
+	done chan struct{}
+	path string
+	err  error
+}
+
+var (
+	cacheOnce sync.Once
+	segCache  *cache.DiskCache
+	cacheErr  error
+
+	inflight   = make(map[string]*call)
+	inflightMu sync.Mutex
+)
+
+// segmentCache 获取分片缓存
+func segmentCache() (*cache.DiskCache, error) {
+	cacheOnce.Do(func() {
+		segCache, cacheErr = cache.New(config.HLSConfig.CacheDir, config.HLSConfig.CacheSize)
+	})
+	return segCache, cacheErr
+}
+
+// segmentSeconds 分片时长
+func segmentSeconds() float64 {
+	if config.HLSConfig.SegmentSeconds > 0 {
+		return float64(config.HLSConfig.SegmentSeconds)
+	}
+	return 6
+}
+
+// evenSize 转为偶数尺寸（libx264 要求）
+func evenSize(n int) int {
+	return n / 2 * 2
+}
+
+// boxWidth 清晰度对应的 16:9 最大宽度
+func boxWidth(height int) int {
+	return evenSize(height * 16 / 9)
+}
+
+// Renditions 返回适用于该视频的清晰度，不超过原始分辨率
+// 宽银幕视频（如 1920x800）按 16:9 画框判断，仍然提供 1080p
+func Renditions(src *Source) []Rendition {
+	var list []Rendition
+	for _, r := range config.HLSConfig.Renditions {
+		if src.Height > 0 && r.Height > src.Height && boxWidth(r.Height) > src.Width {
+			continue
+		}
+		list = append(list, newRendition(src, r, boxWidth(r.Height), r.Height))
+	}
+
+	// 原始分辨率低于所有清晰度时按原始尺寸输出最低码率
+	if len(list) == 0 && len(config.HLSConfig.Renditions) > 0 {
+		lowest := config.HLSConfig.Renditions[len(config.HLSConfig.Renditions)-1]
+		list = append(list, newRendition(src, lowest, src.Width, src.Height))
+	}
+	return list
+}
+
+// newRendition 将原始画面等比缩放到画框内
+func newRendition(src *Source, r config.HLSRendition, boxW, boxH int) Rendition {
+	rendition := Rendition{
+		Name:         r.Name,
+		Height:       evenSize(boxH),
+		VideoBitrate: r.VideoBitrate,
+		AudioBitrate: r.AudioBitrate,
+	}
+	if src.Width > 0 && src.Height > 0 {
+		scale := math.Min(float64(boxW)/float64(src.Width), float64(boxH)/float64(src.Height))
+		scale = math.Min(scale, 1)
+		rendition.Width = evenSize(int(math.Round(float64(src.Width) * scale)))
+		rendition.Height = evenSize(int(math.Round(float64(src.Height) * scale)))
+	}
+	return rendition
+}
+
+// scaleFilter 缩放滤镜，原始尺寸未知时按高度等比缩放
+func (r Rendition) scaleFilter() string {
+	if r.Width > 0 {
+		return fmt.Sprintf("scale=%d:%d", r.Width, r.Height)
+	}
+	return fmt.Sprintf("scale=-2:%d", r.Height)
+}
+
+// h264Level 按输出高度选择的 H.264 level（乘以 10），编码参数和播放列表中的 CODECS 保持一致
+func (r Rendition) h264Level() int {
+	switch {
+	case r.Height <= 480:
+		return 31
+	case r.Height <= 720:
+		return 32
+	case r.Height <= 1080:
+		return 42
+	case r.Height <= 1440:
+		return 50
+	}
+	return 51
+}
+
+// codecs 播放列表中声明的编码：H.264 Main profile 和对应的 level，有音轨时加上 AAC-LC
+func (r Rendition) codecs(audio bool) string {
+	codecs := fmt.Sprintf("avc1.4d40%02x", r.h264Level())
+	if audio {
+		codecs += ",mp4a.40.2"
+	}
+	return codecs
+}
+
+// FindRendition 按名称查找清晰度
+func FindRendition(src *Source, name string) (Rendition, bool) {
+	for _, r := range Renditions(src) {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return Rendition{}, false
+}
+
+// query 播放列表中子地址需要携带的参数，切换音轨时保持一致
+func (src *Source) query() string {
+	if src.AudioStream >= 0 {
+		return fmt.Sprintf("?audio=%d", src.AudioStream)
+	}
+	return ""
+}
+
+// videoMap 选择视频流的 -map 参数，未指定时跳过内嵌封面等图片流
+func (src *Source) videoMap() string {
+	if src.VideoStream >= 0 {
+		return fmt.Sprintf("0:%d", src.VideoStream)
+	}
+	return "0:V:0"
+}
+
+// audioMap 选择音轨的 -map 参数
+func (src *Source) audioMap() string {
+	if src.AudioStream >= 0 {
+		return fmt.Sprintf("0:%d", src.AudioStream)
+	}
+	return "0:a:0?"
+}
+
+// SegmentCount 分片数量
+func SegmentCount(duration float64) int {
+	return int(math.Ceil(duration / segmentSeconds()))
+}
+
+// MasterPlaylist 生成主播放列表
+func MasterPlaylist(src *Source) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n")
+	for _, r := range Renditions(src) {
+		bandwidth := (r.VideoBitrate + r.AudioBitrate) * 1000
+		if r.Width > 0 {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%dx%d,CODECS=\"%s\"\n", bandwidth, r.Width, r.Height, r.codecs(src.Audio))
+		} else {
+			fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"\n", bandwidth, r.codecs(src.Audio))
+		}
+		fmt.Fprintf(&b, "%s/index.m3u8%s\n", r.Name, src.query())
+	}
+	return b.String()
+}
+
+// MediaPlaylist 生成单个清晰度的播放列表，所有分片预先列出以便任意跳转
+func MediaPlaylist(src *Source) string {
+	duration := src.Duration
+	seg := segmentSeconds()
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(seg)))
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	for i := 0; i < SegmentCount(duration); i++ {
+		length := math.Min(seg, duration-float64(i)*seg)
+		fmt.Fprintf(&b, "#EXTINF:%.6f,\n%d.ts%s\n", length, i, src.query())
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
+
+// segmentKey 分片的缓存键，不同音轨分别缓存
+func segmentKey(src *Source, r Rendition, index int) string {
+	if src.AudioStream >= 0 {
+		return fmt.Sprintf("%d_%d/%s_a%d/%d.ts", src.VideoID, src.Version, r.Name, src.AudioStream, index)
+	}
+	return fmt.Sprintf("%d_%d/%s/%d.ts", src.VideoID, src.Version, r.Name, index)
+}
+
+// Segment 返回分片文件路径，未缓存时立即转码，ctx 结束（如客户端断开）时终止转码
+func Segment(ctx context.Context, src *Source, r Rendition, index int) (string, error) {
+	return segment(ctx, src, r, index, true)
+}
+
+// Prefetch 预先转码分片，没有空闲的转码名额或分片正在转码时跳过，ctx 结束时终止转码
+func Prefetch(ctx context.Context, src *Source, r Rendition, index int) {
+	if index < SegmentCount(src.Duration) {
+		segment(ctx, src, r, index, false)
+	}
+}
+
+// segment 获取或转码分片，同一分片同时只转码一次；wait 为 false 时不等待正在进行的转码和转码名额
+func segment(ctx context.Context, src *Source, r Rendition, index int, wait bool) (string, error) {
+	if index < 0 || index >= SegmentCount(src.Duration) {
+		return "", ErrSegmentRange
+	}
+
+	c, err := segmentCache()
+	if err != nil {
+		return "", err
+	}
+
+	key := segmentKey(src, r, index)
+	for {
+		inflightMu.Lock()
+		cl, ok := inflight[key]
+		if !ok {
+			break
+		}
+		inflightMu.Unlock()
+		if !wait {
+			return "", nil
+		}
+
+		select {
+		case <-cl.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		// 发起转码的客户端断开时转码被终止，仍在等待的请求重新转码
+		if cl.err == nil || !errors.Is(cl.err, context.Canceled) {
+			return cl.path, cl.err
+		}
+	}
+	if path, ok := c.Get(key); ok {
+		inflightMu.Unlock()
+		return path, nil
+	}
+	cl := &call{done: make(chan struct{})}
+	inflight[key] = cl
+	inflightMu.Unlock()
+
+	cl.path, cl.err = transcodeSegment(ctx, c, key, src, r, index, wait)
+
+	inflightMu.Lock()
+	delete(inflight, key)
+	inflightMu.Unlock()
+	close(cl.done)
+
+	return cl.path, cl.err
+}
+
+// transcodeSegment 从分片起点开始转码，时间戳与整段视频对齐
+func transcodeSegment(ctx context.Context, c *cache.DiskCache, key string, src *Source, r Rendition, index int, wait bool) (string, error) {
+	seg := segmentSeconds()
+	start := float64(index) * seg
+	length := math.Min(seg, src.Duration-start)
+
+	path := c.Path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := path + cache.TempSuffix
+
+	ctx, cancel := context.WithTimeout(ctx, SegmentTimeout)
+	defer cancel()
+
+	// 预取使用后台任务的名额，不占用正在播放的请求的名额
+	run, kind := transcode.Run, transcode.KindHLS
+	if !wait {
+		run, kind = transcode.TryRun, transcode.KindPrefetch
+	}
+	err := run(ctx, transcode.Session{Kind: kind, VideoID: src.VideoID, Mode: r.Name}, utils.TranscodeOptions{
+		InputArgs: []string{"-ss", fmt.Sprintf("%.3f", start)},
+		Input:     src.Path,
+		Args: []string{
+			"-t", fmt.Sprintf("%.3f", length),
+			"-map", src.videoMap(),
+			"-map", src.audioMap(),
+			"-vf", r.scaleFilter(),
+			"-c:v", "libx264",
+			"-preset", "veryfast",
+			"-profile:v", "main",
+			"-level:v", fmt.Sprintf("%d.%d", r.h264Level()/10, r.h264Level()%10),
+			"-b:v", fmt.Sprintf("%dk", r.VideoBitrate),
+			"-maxrate", fmt.Sprintf("%dk", r.VideoBitrate*107/100),
+			"-bufsize", fmt.Sprintf("%dk", r.VideoBitrate*2),
+			"-c:a", "aac",
+			"-b:a", fmt.Sprintf("%dk", r.AudioBitrate),
+			"-ac", "2",
+			"-output_ts_offset", fmt.Sprintf("%.3f", start),
+			"-muxdelay", "0",
+			"-f", "mpegts",
+		},
+		Output: tmp,
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := c.Put(key); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Purge 删除视频的全部切片缓存
+func Purge(videoID uint) int {
+	c, err := segmentCache()
+	if err != nil {
+		return 0
+	}
+	return c.RemovePrefix(fmt.Sprintf("%d_", videoID))
+}
@@ -0,0 +1,150 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/utils"
+)
+
+var fakeMedia = &utils.FakeMediaTool{}
+
+// TestMain 切片缓存使用临时目录，转码使用 FakeMediaTool
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hidevideo-hls-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	config.HLSConfig.CacheDir = dir
+	utils.Media = fakeMedia
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	// config 包初始化时在当前目录创建的空数据目录
+	os.Remove("data/covers")
+	os.Remove("data")
+	os.Exit(code)
+}
+
+func TestRenditions(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          []string
+	}{
+		{1920, 1080, []string{"1080p 1920x1080", "720p 1280x720", "480p 852x478", "360p 640x360"}},
+		{1920, 800, []string{"1080p 1920x800", "720p 1280x532", "480p 852x354", "360p 640x266"}},
+		{640, 360, []string{"360p 640x360"}},
+		{320, 240, []string{"360p 320x240"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, r := range Renditions(&Source{Width: tt.width, Height: tt.height}) {
+			got = append(got, fmt.Sprintf("%s %dx%d", r.Name, r.Width, r.Height))
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("Renditions(%dx%d) = %v, want %v", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestMasterPlaylist(t *testing.T) {
+	playlist := MasterPlaylist(&Source{Width: 1920, Height: 1080, AudioStream: -1, Audio: true})
+	if n := strings.Count(playlist, "#EXT-X-STREAM-INF"); n != 4 {
+		t.Errorf("%d renditions in master playlist, want 4:\n%s", n, playlist)
+	}
+	if !strings.Contains(playlist, "RESOLUTION=1920x1080") || !strings.Contains(playlist, "\n1080p/index.m3u8\n") {
+		t.Errorf("1080p rendition missing:\n%s", playlist)
+	}
+	// CODECS 的 level 与编码参数一致
+	for _, codecs := range []string{`CODECS="avc1.4d402a,mp4a.40.2"`, `CODECS="avc1.4d401f,mp4a.40.2"`} {
+		if !strings.Contains(playlist, codecs) {
+			t.Errorf("master playlist missing %s:\n%s", codecs, playlist)
+		}
+	}
+
+	// 没有音轨时不声明音频编码
+	if playlist := MasterPlaylist(&Source{Width: 640, Height: 360, AudioStream: -1}); strings.Contains(playlist, "mp4a") {
+		t.Errorf("audio codec listed for a silent source:\n%s", playlist)
+	}
+
+	// 选择音轨时子播放列表携带音轨参数
+	playlist = MasterPlaylist(&Source{Width: 1280, Height: 720, AudioStream: 2})
+	if !strings.Contains(playlist, "\n720p/index.m3u8?audio=2\n") || strings.Contains(playlist, "1080p") {
+		t.Errorf("unexpected master playlist:\n%s", playlist)
+	}
+}
+
+func TestStreamMaps(t *testing.T) {
+	tests := []struct {
+		src          Source
+		video, audio string
+	}{
+		{Source{VideoStream: -1, AudioStream: -1}, "0:V:0", "0:a:0?"},
+		{Source{VideoStream: 1, AudioStream: 3}, "0:1", "0:3"},
+	}
+	for _, tt := range tests {
+		if video, audio := tt.src.videoMap(), tt.src.audioMap(); video != tt.video || audio != tt.audio {
+			t.Errorf("maps = %s %s, want %s %s", video, audio, tt.video, tt.audio)
+		}
+	}
+}
+
+func TestMediaPlaylist(t *testing.T) {
+	playlist := MediaPlaylist(&Source{Duration: 20, AudioStream: 1})
+	for _, line := range []string{
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXTINF:6.000000,\n0.ts?audio=1",
+		"#EXTINF:6.000000,\n2.ts?audio=1",
+		"#EXTINF:2.000000,\n3.ts?audio=1",
+		"#EXT-X-ENDLIST",
+	} {
+		if !strings.Contains(playlist, line) {
+			t.Errorf("media playlist missing %q:\n%s", line, playlist)
+		}
+	}
+	if n := strings.Count(playlist, "#EXTINF"); n != 4 {
+		t.Errorf("%d segments, want 4", n)
+	}
+}
+
+func TestSegment(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "movie.mkv")
+	os.WriteFile(input, []byte("segment"), 0644)
+	src := &Source{VideoID: 1, Path: input, Version: 1, Duration: 20, Width: 1920, Height: 1080, AudioStream: -1}
+	r, ok := FindRendition(src, "720p")
+	if !ok {
+		t.Fatal("720p rendition not found")
+	}
+
+	path, err := Segment(context.Background(), src, r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "FAKE-TRANSCODE "+input {
+		t.Errorf("segment content = %q", data)
+	}
+
+	// 已缓存的分片不重新转码
+	calls := len(fakeMedia.Calls())
+	if again, err := Segment(context.Background(), src, r, 1); err != nil || again != path {
+		t.Errorf("cached segment = %q, %v; want %q", again, err, path)
+	}
+	if len(fakeMedia.Calls()) != calls {
+		t.Error("cached segment transcoded again")
+	}
+
+	if _, err := Segment(context.Background(), src, r, SegmentCount(src.Duration)); err != ErrSegmentRange {
+		t.Errorf("out of range segment error = %v", err)
+	}
+	if n := Purge(src.VideoID); n != 1 {
+		t.Errorf("Purge removed %d segments, want 1", n)
+	}
+}
@@ -0,0 +1,262 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/models"
+)
+
+// 任务状态
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("任务不存在")
+	// ErrFinished 任务已结束，无法取消
+	ErrFinished = errors.New("任务已结束")
+)
+
+// Handler 任务处理函数，返回值会序列化后保存为任务结果
+type Handler func(t *Task) (interface{}, error)
+
+// Task 正在执行的任务
+type Task struct {
+	Ctx context.Context
+	Job *models.Job
+
+	progress  Progress
+	stepStart time.Time
+}
+
+// Decode 解析任务参数
+func (t *Task) Decode(v interface{}) error {
+	if t.Job.Payload == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(t.Job.Payload), v)
+}
+
+// Cancelled 任务是否已被取消
+func (t *Task) Cancelled() bool {
+	return t.Ctx.Err() != nil
+}
+
+var (
+	handlers = make(map[string]Handler)
+	running  = make(map[uint]context.CancelFunc)
+	mu       sync.Mutex
+	wake     chan struct{}
+)
+
+// Register 注册任务类型的处理函数，需在 Start 之前调用
+func Register(jobType string, h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = h
+}
+
+// Start 启动任务工作池
+func Start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	wake = make(chan struct{}, workers)
+
+	// 上次退出时仍在执行的任务重新排队
+	database.DB.Model(&models.Job{}).
+		Where("status = ?", StatusRunning).
+		Updates(map[string]interface{}{"status": StatusQueued, "started_at": nil})
+
+	for i := 0; i < workers; i++ {
+		go worker()
+	}
+}
+
+// Enqueue 创建任务并加入队列
+func Enqueue(jobType string, libraryID uint, payload interface{}) (*models.Job, error) {
+	mu.Lock()
+	_, ok := handlers[jobType]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的任务类型: %s", jobType)
+	}
+
+	job := models.Job{
+		Type:      jobType,
+		LibraryID: libraryID,
+		Status:    StatusQueued,
+	}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = models.JSONText(data)
+	}
+
+	if err := database.DB.Create(&job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+
+	return &job, nil
+}
+
+// Cancel 取消任务，排队中的任务直接标记为取消，执行中的任务通知其停止
+func Cancel(id uint) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var job models.Job
+	if err := database.DB.First(&job, id).Error; err != nil {
+		return ErrNotFound
+	}
+
+	switch job.Status {
+	case StatusQueued:
+		now := time.Now()
+		database.DB.Model(&job).Updates(map[string]interface{}{
+			"status":      StatusCancelled,
+			"finished_at": &now,
+		})
+		return nil
+	case StatusRunning:
+		if cancel, ok := running[id]; ok {
+			cancel()
+		}
+		return nil
+	default:
+		return ErrFinished
+	}
+}
+
+// worker 循环领取并执行任务
+func worker() {
+	for {
+		job, ctx, ok := claimNext()
+		if !ok {
+			select {
+			case <-wake:
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		run(ctx, job)
+	}
+}
+
+// claimNext 领取最早排队的任务
+func claimNext() (*models.Job, context.Context, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var job models.Job
+	if err := database.DB.Where("status = ?", StatusQueued).Order("id ASC").First(&job).Error; err != nil {
+		return nil, nil, false
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ?", job.ID, StatusQueued).
+		Updates(map[string]interface{}{"status": StatusRunning, "started_at": &now})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, nil, false
+	}
+	job.Status = StatusRunning
+	job.StartedAt = &now
+
+	ctx, cancel := context.WithCancel(context.Background())
+	running[job.ID] = cancel
+
+	return &job, ctx, true
+}
+
+// run 执行单个任务并保存结果
+func run(ctx context.Context, job *models.Job) {
+	mu.Lock()
+	h := handlers[job.Type]
+	mu.Unlock()
+
+	t := &Task{
+		Ctx: ctx,
+		Job: job,
+		progress: Progress{
+			JobID:  job.ID,
+			Type:   job.Type,
+			Status: StatusRunning,
+			ETA:    -1,
+		},
+	}
+	publish(t.progress)
+
+	result, err := execute(h, t)
+
+	// 先判断是否被取消，再释放上下文
+	cancelled := ctx.Err() != nil
+
+	mu.Lock()
+	if cancel, ok := running[job.ID]; ok {
+		cancel()
+	}
+	delete(running, job.ID)
+	mu.Unlock()
+
+	status := StatusDone
+	errMsg := ""
+	switch {
+	case cancelled:
+		status = StatusCancelled
+	case err != nil:
+		status = StatusFailed
+		errMsg = err.Error()
+	}
+
+	updates := map[string]interface{}{
+		"status":      status,
+		"error":       errMsg,
+		"finished_at": time.Now(),
+	}
+	if result != nil {
+		if data, err := json.Marshal(result); err == nil {
+			updates["result"] = string(data)
+		}
+	}
+	database.DB.Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates)
+
+	// 推送结束事件
+	t.progress.Status = status
+	t.progress.ETA = 0
+	if errMsg != "" {
+		t.progress.LastError = errMsg
+	}
+	publish(t.progress)
+}
+
+// execute 调用处理函数，捕获 panic
+func execute(h Handler, t *Task) (result interface{}, err error) {
+	if h == nil {
+		return nil, fmt.Errorf("未知的任务类型: %s", t.Job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务异常: %v", r)
+		}
+	}()
+	return h(t)
+}
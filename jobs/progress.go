@@ -0,0 +1,110 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+)
+
+// Progress 任务进度事件
+type Progress struct {
+	JobID     uint    `json:"job_id"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	Current   string  `json:"current"`    // 当前处理的文件
+	Processed int     `json:"processed"`  // 已处理数量
+	Total     int     `json:"total"`      // 总数量
+	Errors    int     `json:"errors"`     // 出错数量
+	LastError string  `json:"last_error"` // 最近一次错误
+	ETA       float64 `json:"eta"`        // 预计剩余秒数，未知时为 -1
+}
+
+var (
+	subscribers = make(map[chan Progress]uint)
+	latest      = make(map[uint]Progress)
+	progressMu  sync.RWMutex
+)
+
+// Subscribe 订阅任务进度，jobID 为 0 时订阅全部任务，返回的函数用于取消订阅
+func Subscribe(jobID uint) (<-chan Progress, func()) {
+	ch := make(chan Progress, 64)
+
+	progressMu.Lock()
+	subscribers[ch] = jobID
+	// 先推送当前进度，便于中途订阅的客户端立即显示
+	for id, p := range latest {
+		if jobID == 0 || jobID == id {
+			select {
+			case ch <- p:
+			default:
+			}
+		}
+	}
+	progressMu.Unlock()
+
+	return ch, func() {
+		progressMu.Lock()
+		delete(subscribers, ch)
+		progressMu.Unlock()
+	}
+}
+
+// Snapshot 获取执行中任务的最新进度
+func Snapshot(jobID uint) (Progress, bool) {
+	progressMu.RLock()
+	defer progressMu.RUnlock()
+	p, ok := latest[jobID]
+	return p, ok
+}
+
+// publish 广播进度事件，订阅方处理不及时则丢弃
+func publish(p Progress) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+
+	if p.Status == StatusRunning {
+		latest[p.JobID] = p
+	} else {
+		delete(latest, p.JobID)
+	}
+
+	for ch, id := range subscribers {
+		if id != 0 && id != p.JobID {
+			continue
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// SetTotal 设置任务需要处理的总数量
+func (t *Task) SetTotal(total int) {
+	t.progress.Total = total
+	t.progress.ETA = -1
+	t.stepStart = time.Now()
+
+	publish(t.progress)
+}
+
+// Step 记录一项处理完成，err 不为空时计入错误数
+func (t *Task) Step(current string, err error) {
+	t.progress.Current = current
+	t.progress.Processed++
+	if err != nil {
+		t.progress.Errors++
+		t.progress.LastError = err.Error()
+	}
+
+	t.progress.ETA = -1
+	if t.progress.Total > 0 {
+		elapsed := time.Since(t.stepStart).Seconds()
+		remaining := t.progress.Total - t.progress.Processed
+		if remaining < 0 {
+			remaining = 0
+		}
+		t.progress.ETA = elapsed / float64(t.progress.Processed) * float64(remaining)
+	}
+
+	publish(t.progress)
+}
@@ -6,6 +6,8 @@ import (
 	"hidevideo/backend/config"
 	"hidevideo/backend/database"
 	"hidevideo/backend/handlers"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/scheduler"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
@@ -18,6 +20,19 @@ func main() {
 		panic(err)
 	}
 
+	// 启动后台任务
+	handlers.RegisterJobs()
+	jobs.Start(config.JobConfig.Workers)
+
+	// 启动视频库目录监听
+	handlers.StartWatchers()
+
+	// 启动定时维护计划
+	scheduler.Start()
+
+	// 定期删除过期的导出片段
+	handlers.StartClipCleaner()
+
 	// 初始化 Gin
 	gin.SetMode(gin.ReleaseMode)
 	r := gin.Default()
@@ -25,6 +40,10 @@ func main() {
 	// 设置静态文件服务
 	// 视频封面
 	r.Static("/covers", config.ServerConfig.StaticPath)
+	// 拖动预览缩略图
+	r.Static("/sprites", config.SpriteConfig.Dir)
+	// 视频图标
+	r.Static("/icons", config.IconConfig.Dir)
 
 	// 设置 Session
 	store := cookie.NewStore([]byte(config.SessionConfig.Secret))
@@ -69,16 +88,79 @@ func main() {
 				libraries.GET("/:id/path", handlers.GetLibraryPath)
 				libraries.GET("/:id/files", handlers.ListLibraryFiles)
 				libraries.POST("/:id/icon", handlers.GenerateIcon)
+				libraries.PUT("/:id/watch", handlers.SetLibraryWatch)
+				libraries.PUT("/:id/settings", handlers.UpdateLibrarySettings)
+				libraries.POST("/:id/optimize", handlers.OptimizeLibrary)
+				libraries.POST("/:id/sprites", handlers.GenerateSprites)
+				libraries.POST("/:id/preview", handlers.GeneratePreviews)
+				libraries.POST("/:id/images", handlers.RegenerateImages)
+				libraries.POST("/:id/scenes", handlers.DetectLibraryScenes)
 			}
 
+			// 定时计划
+			schedules := protected.Group("/schedules")
+			{
+				schedules.GET("", handlers.GetSchedules)
+				schedules.POST("", handlers.AddSchedule)
+				schedules.PUT("/:id/enabled", handlers.SetScheduleEnabled)
+				schedules.DELETE("/:id", handlers.DeleteSchedule)
+			}
+
+			// 后台任务
+			jobsGroup := protected.Group("/jobs")
+			{
+				jobsGroup.GET("", handlers.GetJobs)
+				jobsGroup.GET("/events", handlers.GetJobEvents)
+				jobsGroup.GET("/:id", handlers.GetJob)
+				jobsGroup.GET("/:id/events", handlers.GetJobEvents)
+				jobsGroup.DELETE("/:id", handlers.CancelJob)
+			}
+
+			// 管理员
+			admin := protected.Group("/admin")
+			admin.Use(handlers.AdminMiddleware())
+			{
+				admin.GET("/transcodes", handlers.GetTranscodeSessions)
+				admin.DELETE("/transcodes", handlers.StopTranscodeSession)
+				admin.DELETE("/transcodes/:id", handlers.StopTranscodeSession)
+				admin.DELETE("/transcode-cache", handlers.ClearTranscodeCache)
+			}
+
+			// 预转码缓存
+			protected.GET("/transcode-cache", handlers.GetTranscodeCache)
+
 			// 视频管理
 			videos := protected.Group("/videos")
 			{
 				videos.GET("", handlers.GetVideos)
 				videos.GET("/folders", handlers.GetFolderTree)
 				videos.GET("/by-path", handlers.GetVideoByPath)
+				videos.POST("/optimize", handlers.OptimizeVideos)
 				videos.GET("/:id", handlers.GetVideo)
 				videos.GET("/:id/stream", handlers.StreamVideo)
+				videos.GET("/:id/playback", handlers.GetPlaybackInfo)
+				videos.GET("/:id/audio", handlers.GetAudioTracks)
+				videos.GET("/:id/cover-candidates", handlers.GetCoverCandidates)
+				videos.POST("/:id/cover-candidates", handlers.GenerateCoverCandidates)
+				videos.PUT("/:id/cover", handlers.SetVideoCover)
+				videos.POST("/:id/cover", handlers.UploadVideoCover)
+				videos.POST("/:id/icon", handlers.GenerateSingleIcon)
+				videos.GET("/:id/subtitles", handlers.GetSubtitles)
+				videos.POST("/:id/subtitles", handlers.UploadSubtitle)
+				videos.GET("/:id/subtitles/:sid", handlers.GetSubtitle)
+				videos.DELETE("/:id/subtitles/:sid", handlers.DeleteSubtitle)
+				videos.GET("/:id/chapters", handlers.GetChapters)
+				videos.POST("/:id/chapters", handlers.AddChapter)
+				videos.PUT("/:id/chapters/:cid", handlers.UpdateChapter)
+				videos.DELETE("/:id/chapters/:cid", handlers.DeleteChapter)
+				videos.GET("/:id/chapters/:cid/thumbnail", handlers.GetChapterThumbnail)
+				videos.POST("/:id/scenes", handlers.DetectVideoScenes)
+				videos.POST("/:id/clips", handlers.CreateClip)
+				videos.GET("/:id/images", handlers.GetVideoImages)
+				videos.GET("/:id/image", handlers.GetVideoImage)
+				videos.GET("/:id/hls/master.m3u8", handlers.GetHLSMaster)
+				videos.GET("/:id/hls/:rendition/index.m3u8", handlers.GetHLSPlaylist)
+				videos.GET("/:id/hls/:rendition/:segment", handlers.GetHLSSegment)
 				videos.PUT("/:id/rating", handlers.UpdateRating)
 				videos.PUT("/:id/filename", handlers.UpdateVideoFilename)
 				videos.POST("/:id/play", handlers.IncrementPlayCount)
@@ -94,6 +176,9 @@ func main() {
 				videos.POST("/:id/comments", handlers.AddComment)
 			}
 
+			// 导出的片段
+			protected.GET("/clips/:id", handlers.DownloadClip)
+
 			// 评论管理
 			protected.DELETE("/comments/:id", handlers.DeleteComment)
 
@@ -139,6 +224,7 @@ func main() {
 				users.PUT("/password", handlers.UpdateUserPassword)
 				users.PUT("/info", handlers.UpdateUserInfo)
 				users.GET("/me", handlers.GetCurrentUser)
+				users.PUT("/preferences", handlers.UpdateUserPreferences)
 			}
 		}
 
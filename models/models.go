@@ -13,18 +13,26 @@ type User struct {
 	Password     string         `gorm:"size:255;not null" json:"-"`
 	PasswordPlain string        `gorm:"size:255" json:"-"`
 	Role        string         `gorm:"size:20;default:'member'" json:"role"`
+	AudioLanguage string       `gorm:"size:20" json:"audio_language"` // 偏好的音轨语言
 	CreatedAt    time.Time      `json:"created_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // VideoLibrary 视频库表
 type VideoLibrary struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"size:100;not null;unique" json:"name"`
-	Path      string         `gorm:"size:500" json:"path"`
-	CreatedAt time.Time      `json:"created_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Videos    []Video        `gorm:"foreignKey:LibraryID" json:"-"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	Name            string         `gorm:"size:100;not null;unique" json:"name"`
+	Path            string         `gorm:"size:500" json:"path"`
+	Watch           bool           `gorm:"default:false" json:"watch"`
+	ExcludePatterns string         `gorm:"type:text" json:"exclude_patterns"` // 排除规则，每行一条
+	MinFileSize     int64          `gorm:"default:0" json:"min_file_size"`    // 最小文件大小（字节）
+	MinDuration     float64        `gorm:"default:0" json:"min_duration"`     // 最小时长（秒）
+	FollowSymlinks  bool           `gorm:"default:false" json:"follow_symlinks"`
+	ExtraExtensions string         `gorm:"size:500" json:"extra_extensions"` // 额外扩展名，逗号分隔
+	SceneThreshold  float64        `gorm:"default:0" json:"scene_threshold"` // 场景检测阈值（0~1），0 使用默认值
+	CreatedAt       time.Time      `json:"created_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	Videos          []Video        `gorm:"foreignKey:LibraryID" json:"-"`
 }
 
 // Video 视频表
@@ -37,16 +45,94 @@ type Video struct {
 	Width      int            `gorm:"default:0" json:"width"`
 	Height     int            `gorm:"default:0" json:"height"`
 	Codec      string         `gorm:"size:50" json:"codec"`
+	FileSize   int64          `gorm:"default:0" json:"file_size"`
+	ModTime    time.Time      `json:"mod_time"`
+	Inode      uint64         `gorm:"default:0" json:"-"`
+	ContentHash string        `gorm:"size:40;index" json:"-"`
 	CreatedAt  time.Time      `json:"created_at"`
 	PlayCount  int            `gorm:"default:0" json:"play_count"`
 	Rating     float64        `gorm:"default:0" json:"rating"`
 	CoverPath  string         `gorm:"size:500" json:"cover_path"`
 	IconPath   string         `gorm:"size:500" json:"icon_path"`
+	ThumbnailsPath string     `gorm:"size:500" json:"thumbnails_path"`
+	PreviewPath string        `gorm:"size:500" json:"preview_path"`
 	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 	Library    VideoLibrary   `gorm:"foreignKey:LibraryID" json:"-"`
 	Tags       []Tag          `gorm:"many2many:video_tags;" json:"tags"`
 	Actors     []Actor       `gorm:"many2many:video_actors;" json:"actors"`
 	Comments   []Comment      `gorm:"foreignKey:VideoID" json:"comments"`
+	Streams    []VideoStream  `gorm:"foreignKey:VideoID" json:"streams,omitempty"`
+	Format     *VideoFormat   `gorm:"foreignKey:VideoID" json:"format,omitempty"`
+	Subtitles  []Subtitle     `gorm:"foreignKey:VideoID" json:"subtitles,omitempty"`
+	Chapters   []Chapter      `gorm:"foreignKey:VideoID" json:"chapters,omitempty"`
+}
+
+// VideoStream 媒体流表（视频/音频/字幕）
+type VideoStream struct {
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	VideoID        uint    `gorm:"index;not null" json:"video_id"`
+	StreamIndex    int     `gorm:"default:0" json:"index"`
+	Type           string  `gorm:"size:20" json:"type"`
+	Codec          string  `gorm:"size:50" json:"codec"`
+	Profile        string  `gorm:"size:50" json:"profile"`
+	Language       string  `gorm:"size:20" json:"language"`
+	Title          string  `gorm:"size:255" json:"title"`
+	Default        bool    `gorm:"default:false" json:"default"`
+	Forced         bool    `gorm:"default:false" json:"forced"`
+	AttachedPic    bool    `gorm:"default:false" json:"attached_pic,omitempty"` // 内嵌封面图片
+	BitRate        int64   `gorm:"default:0" json:"bit_rate"`
+	Width          int     `gorm:"default:0" json:"width,omitempty"`
+	Height         int     `gorm:"default:0" json:"height,omitempty"`
+	FrameRate      float64 `gorm:"default:0" json:"frame_rate,omitempty"`
+	PixelFormat    string  `gorm:"size:30" json:"pixel_format,omitempty"`
+	ColorTransfer  string  `gorm:"size:30" json:"color_transfer,omitempty"`
+	ColorPrimaries string  `gorm:"size:30" json:"color_primaries,omitempty"`
+	HDR            string  `gorm:"size:20" json:"hdr,omitempty"` // HDR10 / HLG / DolbyVision
+	Channels       int     `gorm:"default:0" json:"channels,omitempty"`
+	ChannelLayout  string  `gorm:"size:30" json:"channel_layout,omitempty"`
+	SampleRate     int     `gorm:"default:0" json:"sample_rate,omitempty"`
+}
+
+// VideoFormat 容器信息表
+type VideoFormat struct {
+	ID             uint     `gorm:"primaryKey" json:"id"`
+	VideoID        uint     `gorm:"uniqueIndex;not null" json:"video_id"`
+	FormatName     string   `gorm:"size:100" json:"format_name"`
+	FormatLongName string   `gorm:"size:255" json:"format_long_name"`
+	Duration       float64  `gorm:"default:0" json:"duration"`
+	BitRate        int64    `gorm:"default:0" json:"bit_rate"`
+	Size           int64    `gorm:"default:0" json:"size"`
+	Title          string   `gorm:"size:500" json:"title"`
+	Tags           JSONText `gorm:"type:text" json:"tags"`
+}
+
+// Subtitle 字幕表（外挂字幕文件、内嵌字幕流或用户上传）
+type Subtitle struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	VideoID     uint      `gorm:"index;not null" json:"video_id"`
+	Source      string    `gorm:"size:20;not null" json:"source"` // sidecar / embedded / upload
+	Path        string    `gorm:"size:500" json:"-"`              // 外挂或上传字幕的文件路径
+	StreamIndex int       `gorm:"default:0" json:"stream_index"`  // 内嵌字幕的流序号
+	Format      string    `gorm:"size:30" json:"format"`          // srt / ass / ssa / vtt 或内嵌字幕编码
+	Language    string    `gorm:"size:20" json:"language"`
+	Title       string    `gorm:"size:255" json:"title"`
+	Default     bool      `gorm:"default:false" json:"default"`
+	Forced      bool      `gorm:"default:false" json:"forced"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Chapter 章节表（容器内嵌章节、用户创建或场景检测生成的章节）
+type Chapter struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	VideoID       uint      `gorm:"index;not null" json:"video_id"`
+	Source        string    `gorm:"size:20;not null" json:"source"` // embedded / user / scene
+	Title         string    `gorm:"size:255" json:"title"`
+	Start         float64   `gorm:"default:0" json:"start"`
+	End           float64   `gorm:"default:0" json:"end"` // 0 表示到下一章节开始
+	ThumbnailPath string    `gorm:"size:500" json:"-"`
+	UserID        uint      `gorm:"index" json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // Tag 标签表
@@ -104,3 +190,42 @@ type Folder struct {
 	CreatedAt time.Time      `json:"created_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
+
+// JSONText 以文本形式存储的 JSON 字段，输出时保持原始 JSON 结构
+type JSONText string
+
+// MarshalJSON 原样输出 JSON 内容，空值输出 null
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
+
+// Job 后台任务表
+type Job struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Type       string     `gorm:"size:50;index;not null" json:"type"`
+	LibraryID  uint       `gorm:"index" json:"library_id"`
+	Status     string     `gorm:"size:20;index;not null" json:"status"`
+	Payload    JSONText   `gorm:"type:text" json:"payload"`
+	Result     JSONText   `gorm:"type:text" json:"result"`
+	Error      string     `gorm:"type:text" json:"error"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Schedule 定时维护计划表
+type Schedule struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	LibraryID uint       `gorm:"index" json:"library_id"`
+	Task      string     `gorm:"size:50;not null" json:"task"`
+	Cron      string     `gorm:"size:100;not null" json:"cron"`
+	Payload   JSONText   `gorm:"type:text" json:"payload"`
+	Enabled   bool       `json:"enabled"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	LastJobID *uint      `json:"last_job_id"`
+	LastJob   *Job       `gorm:"foreignKey:LastJobID" json:"last_job"`
+	CreatedAt time.Time  `json:"created_at"`
+}
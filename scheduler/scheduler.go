@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"hidevideo/backend/database"
+	"hidevideo/backend/jobs"
+	"hidevideo/backend/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	runner  *cron.Cron
+	entries = make(map[uint]cron.EntryID)
+	mu      sync.Mutex
+)
+
+// Parse 校验 cron 表达式（标准五段格式，支持 @daily 等描述符）
+func Parse(expr string) error {
+	_, err := cron.ParseStandard(expr)
+	return err
+}
+
+// Start 加载已启用的计划并启动调度
+func Start() {
+	mu.Lock()
+	runner = cron.New()
+	mu.Unlock()
+
+	var schedules []models.Schedule
+	database.DB.Where("enabled = ?", true).Find(&schedules)
+	for _, s := range schedules {
+		if err := Reload(s); err != nil {
+			log.Printf("加载定时计划 %d 失败: %v", s.ID, err)
+		}
+	}
+
+	runner.Start()
+}
+
+// Reload 根据计划的最新状态重新注册，已禁用的计划会被移除
+func Reload(s models.Schedule) error {
+	Remove(s.ID)
+	if !s.Enabled {
+		return nil
+	}
+
+	id := s.ID
+	entryID, err := runner.AddFunc(s.Cron, func() { trigger(id) })
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	entries[s.ID] = entryID
+	mu.Unlock()
+	return nil
+}
+
+// Remove 移除计划
+func Remove(scheduleID uint) {
+	mu.Lock()
+	defer mu.Unlock()
+	if entryID, ok := entries[scheduleID]; ok {
+		runner.Remove(entryID)
+		delete(entries, scheduleID)
+	}
+}
+
+// Next 获取计划的下次执行时间
+func Next(scheduleID uint) *time.Time {
+	mu.Lock()
+	entryID, ok := entries[scheduleID]
+	mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	next := runner.Entry(entryID).Next
+	if next.IsZero() {
+		return nil
+	}
+	return &next
+}
+
+// trigger 到点后创建对应的后台任务，同一视频库的同类任务未完成时不重复创建
+func trigger(scheduleID uint) {
+	var s models.Schedule
+	if err := database.DB.First(&s, scheduleID).Error; err != nil || !s.Enabled {
+		return
+	}
+
+	// 上次创建的同类任务仍在排队或执行时跳过本次
+	var pending int64
+	database.DB.Model(&models.Job{}).
+		Where("type = ? AND library_id = ? AND status IN ?", s.Task, s.LibraryID, []string{jobs.StatusQueued, jobs.StatusRunning}).
+		Count(&pending)
+	if pending > 0 {
+		log.Printf("定时计划 %d 的上一个任务尚未完成，跳过本次执行", s.ID)
+		return
+	}
+
+	var payload interface{}
+	if s.Payload != "" {
+		payload = json.RawMessage(s.Payload)
+	}
+
+	job, err := jobs.Enqueue(s.Task, s.LibraryID, payload)
+	if err != nil {
+		log.Printf("定时计划 %d 创建任务失败: %v", s.ID, err)
+		return
+	}
+
+	now := time.Now()
+	database.DB.Model(&s).Updates(map[string]interface{}{
+		"last_run_at": &now,
+		"last_job_id": job.ID,
+	})
+}
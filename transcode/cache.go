@@ -0,0 +1,101 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"hidevideo/backend/cache"
+	"hidevideo/backend/config"
+	"hidevideo/backend/utils"
+)
+
+var (
+	cacheOnce sync.Once
+	fileCache *cache.DiskCache
+	cacheErr  error
+)
+
+// optimizedCache 获取预转码文件缓存
+func optimizedCache() (*cache.DiskCache, error) {
+	cacheOnce.Do(func() {
+		fileCache, cacheErr = cache.New(config.TranscodeConfig.CacheDir, config.TranscodeConfig.CacheSize)
+	})
+	return fileCache, cacheErr
+}
+
+// cacheKey 预转码文件的缓存键，version 为源文件修改时间
+func cacheKey(videoID uint, version int64) string {
+	return fmt.Sprintf("%d_%d.mp4", videoID, version)
+}
+
+// Cached 返回已预转码的文件路径
+func Cached(videoID uint, version int64) (string, bool) {
+	c, err := optimizedCache()
+	if err != nil {
+		return "", false
+	}
+	return c.Get(cacheKey(videoID, version))
+}
+
+// Optimize 将视频转码为适合网页播放的 MP4 并保存到缓存，ctx 取消时终止转码
+func Optimize(ctx context.Context, videoID uint, version int64, input string, args []string) (string, error) {
+	c, err := optimizedCache()
+	if err != nil {
+		return "", err
+	}
+
+	// 源文件变化后旧版本不再使用
+	c.RemovePrefix(fmt.Sprintf("%d_", videoID))
+
+	key := cacheKey(videoID, version)
+	path := c.Path(key)
+	tmp := path + cache.TempSuffix
+
+	err = Run(ctx, Session{Kind: KindOptimize, VideoID: videoID}, utils.TranscodeOptions{
+		Input:  input,
+		Args:   args,
+		Output: tmp,
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := c.Put(key); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Purge 删除视频的预转码文件
+func Purge(videoID uint) int {
+	c, err := optimizedCache()
+	if err != nil {
+		return 0
+	}
+	return c.RemovePrefix(fmt.Sprintf("%d_", videoID))
+}
+
+// Clear 删除全部预转码文件
+func Clear() int {
+	c, err := optimizedCache()
+	if err != nil {
+		return 0
+	}
+	return c.Clear()
+}
+
+// Stats 返回缓存文件数和总大小
+func Stats() (int, int64) {
+	c, err := optimizedCache()
+	if err != nil {
+		return 0, 0
+	}
+	return c.Stats()
+}
@@ -0,0 +1,207 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/utils"
+)
+
+// 转码会话类型
+const (
+	KindStream   = "stream"   // 边转码边播放
+	KindHLS      = "hls"      // HLS 分片
+	KindSubtitle = "subtitle" // 内嵌字幕提取
+	KindOptimize = "optimize" // 预转码任务
+	KindScene    = "scene"    // 场景检测任务
+	KindClip     = "clip"     // 片段导出任务
+	KindPreview  = "preview"  // 悬停预览生成任务
+	KindFrames   = "frames"   // 截帧任务（候选封面、缩略图）
+	KindPrefetch = "prefetch" // HLS 分片预取
+)
+
+// ErrBusy 等待超时仍没有空闲的转码名额
+var ErrBusy = errors.New("转码任务过多，请稍后再试")
+
+// Session 正在运行的 ffmpeg 转码进程
+type Session struct {
+	ID        uint64    `json:"id"`
+	Kind      string    `json:"kind"`
+	VideoID   uint      `json:"video_id"`
+	Mode      string    `json:"mode,omitempty"`
+	Client    string    `json:"client,omitempty"`
+	User      string    `json:"user,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+
+	cancel context.CancelFunc
+}
+
+var (
+	sessions   = make(map[uint64]*Session)
+	nextID     uint64
+	sessionsMu sync.Mutex
+
+	slotsOnce       sync.Once
+	slots           chan struct{} // 播放使用的转码名额
+	backgroundSlots chan struct{} // 后台任务使用的转码名额
+)
+
+// background 是否为后台任务，后台任务使用单独的名额，不占用播放的名额
+func background(kind string) bool {
+	return kind == KindOptimize || kind == KindScene || kind == KindClip || kind == KindPreview || kind == KindFrames || kind == KindPrefetch
+}
+
+// slotPool 返回该类型使用的名额池
+func slotPool(kind string) chan struct{} {
+	slotsOnce.Do(func() {
+		slots = make(chan struct{}, atLeastOne(config.TranscodeConfig.MaxSessions))
+		backgroundSlots = make(chan struct{}, atLeastOne(config.TranscodeConfig.MaxBackground))
+	})
+	if background(kind) {
+		return backgroundSlots
+	}
+	return slots
+}
+
+// atLeastOne 名额数至少为 1
+func atLeastOne(n int) int {
+	if n <= 0 {
+		return 1
+	}
+	return n
+}
+
+// tryAcquireSlot 有空闲名额时立即获取，没有时返回 ErrBusy
+func tryAcquireSlot(kind string) (chan struct{}, error) {
+	pool := slotPool(kind)
+	select {
+	case pool <- struct{}{}:
+		return pool, nil
+	default:
+		return nil, ErrBusy
+	}
+}
+
+// acquireSlot 等待空闲的转码名额，ctx 结束或等待超时时返回错误，返回获取到名额的名额池
+// 后台任务一直等待到有空闲名额或任务被取消
+func acquireSlot(ctx context.Context, kind string) (chan struct{}, error) {
+	pool := slotPool(kind)
+
+	var timeout <-chan time.Time
+	if !background(kind) {
+		timer := time.NewTimer(config.TranscodeConfig.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case pool <- struct{}{}:
+		return pool, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout:
+		return nil, ErrBusy
+	}
+}
+
+// Run 在并发上限内执行转码，ctx 取消（如客户端断开）或会话被终止时结束 ffmpeg 进程
+func Run(ctx context.Context, info Session, opts utils.TranscodeOptions) error {
+	pool, err := acquireSlot(ctx, info.Kind)
+	if err != nil {
+		return err
+	}
+	return run(ctx, pool, info, opts)
+}
+
+// TryRun 与 Run 相同，但没有空闲名额时不等待，直接返回 ErrBusy，用于预取等可以跳过的转码
+func TryRun(ctx context.Context, info Session, opts utils.TranscodeOptions) error {
+	pool, err := tryAcquireSlot(info.Kind)
+	if err != nil {
+		return err
+	}
+	return run(ctx, pool, info, opts)
+}
+
+// Do 在并发上限内执行 fn（如多次截帧），作为一个会话显示和终止，fn 应在 ctx 结束时尽快返回
+func Do(ctx context.Context, info Session, fn func(ctx context.Context) error) error {
+	pool, err := acquireSlot(ctx, info.Kind)
+	if err != nil {
+		return err
+	}
+	return do(ctx, pool, info, fn)
+}
+
+// run 在已获取的名额内执行转码，结束后释放名额
+func run(ctx context.Context, pool chan struct{}, info Session, opts utils.TranscodeOptions) error {
+	return do(ctx, pool, info, func(ctx context.Context) error {
+		return utils.Media.Transcode(ctx, opts)
+	})
+}
+
+// do 在已获取的名额内执行 fn 并登记会话，结束后释放名额
+func do(ctx context.Context, pool chan struct{}, info Session, fn func(ctx context.Context) error) error {
+	defer func() { <-pool }()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s := info
+	s.StartedAt = time.Now()
+	s.cancel = cancel
+
+	sessionsMu.Lock()
+	nextID++
+	s.ID = nextID
+	sessions[s.ID] = &s
+	sessionsMu.Unlock()
+
+	defer func() {
+		sessionsMu.Lock()
+		delete(sessions, s.ID)
+		sessionsMu.Unlock()
+	}()
+
+	return fn(ctx)
+}
+
+// List 返回正在运行的转码会话，按开始时间排序
+func List() []Session {
+	sessionsMu.Lock()
+	list := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, *s)
+	}
+	sessionsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// Stop 终止转码会话，会话不存在时返回 false
+func Stop(id uint64) bool {
+	sessionsMu.Lock()
+	s, ok := sessions[id]
+	sessionsMu.Unlock()
+
+	if ok {
+		s.cancel()
+	}
+	return ok
+}
+
+// StopAll 终止全部转码会话，返回终止的数量
+func StopAll() int {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	for _, s := range sessions {
+		s.cancel()
+	}
+	return len(sessions)
+}
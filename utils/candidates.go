@@ -0,0 +1,199 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"hidevideo/backend/config"
+)
+
+// CoverCandidate 封面候选帧
+type CoverCandidate struct {
+	Second float64 `json:"second"`
+	Score  float64 `json:"score"`
+	Path   string  `json:"-"`
+}
+
+// CandidateDir 视频封面候选帧目录
+func CandidateDir(videoID uint) string {
+	return filepath.Join(config.ServerConfig.StaticPath, "candidates", fmt.Sprintf("%d", videoID))
+}
+
+// CandidatePath 指定时间点候选帧的文件路径（以毫秒命名）
+func CandidatePath(videoID uint, second float64) string {
+	return filepath.Join(CandidateDir(videoID), fmt.Sprintf("%d.jpg", int64(math.Round(second*1000))))
+}
+
+// candidateIndexPath 候选帧列表文件，保存生成时的时间点和评分
+func candidateIndexPath(videoID uint) string {
+	return filepath.Join(CandidateDir(videoID), "candidates.json")
+}
+
+// RemoveCoverCandidates 删除视频的全部候选帧
+func RemoveCoverCandidates(videoID uint) error {
+	return os.RemoveAll(CandidateDir(videoID))
+}
+
+// ListCoverCandidates 列出已生成的候选帧，按时间排序，评分为生成时挑选候选帧所用的评分
+func ListCoverCandidates(videoID uint) []CoverCandidate {
+	data, err := os.ReadFile(candidateIndexPath(videoID))
+	if err != nil {
+		return nil
+	}
+	var saved []CoverCandidate
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil
+	}
+
+	var list []CoverCandidate
+	for _, c := range saved {
+		c.Path = CandidatePath(videoID, c.Second)
+		if FileExists(c.Path) {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Second < list[j].Second
+	})
+	return list
+}
+
+// GenerateCoverCandidates 截取多个候选封面，替换之前的候选帧，ctx 结束时停止截取
+// byScene 为 true 时先截取 3 倍数量的帧，按画面丰富程度和与前一帧的差异挑选最好的 count 个
+func GenerateCoverCandidates(ctx context.Context, videoPath string, videoID uint, duration float64, count int, byScene bool) ([]CoverCandidate, error) {
+	if duration <= 0 {
+		info, err := GetVideoInfo(videoPath)
+		if err != nil {
+			return nil, err
+		}
+		duration = info.Duration
+	}
+	if duration <= 0 {
+		return nil, fmt.Errorf("无法获取视频时长")
+	}
+	if count <= 0 {
+		count = 6
+	}
+
+	dir := CandidateDir(videoID)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	samples := count
+	if byScene {
+		samples = count * 3
+	}
+
+	// 避开片头片尾，在 5%~95% 范围内均匀取点
+	var candidates []CoverCandidate
+	var prev image.Image
+	width, height := CoverSize()
+	for i := 0; i < samples; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		second := duration * (0.05 + 0.9*(float64(i)+0.5)/float64(samples))
+		path := CandidatePath(videoID, second)
+		if err := Media.ExtractFrame(videoPath, second, path, FrameOptions{Width: width, Height: height, Context: ctx}); err != nil {
+			continue
+		}
+		img, err := decodeJPEG(path)
+		if err != nil {
+			os.Remove(path)
+			continue
+		}
+		candidates = append(candidates, CoverCandidate{Second: second, Score: FrameScore(img, prev), Path: path})
+		prev = img
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("截取候选封面失败")
+	}
+
+	if byScene && len(candidates) > count {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Score > candidates[j].Score
+		})
+		for _, c := range candidates[count:] {
+			os.Remove(c.Path)
+		}
+		candidates = candidates[:count]
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].Second < candidates[j].Second
+		})
+	}
+
+	data, err := json.Marshal(candidates)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(candidateIndexPath(videoID), data, 0644); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// FrameScore 评估画面的丰富程度（0~1）：亮度标准差，加上与前一帧的平均差异（场景变化）
+// 纯黑、纯白或单色画面接近 0
+func FrameScore(img, prev image.Image) float64 {
+	lum := sampleLuma(img)
+	if len(lum) == 0 {
+		return 0
+	}
+
+	_, stddev := lumaStats(lum)
+	score := stddev * 2
+	n := float64(len(lum))
+
+	if prev != nil {
+		if prevLum := sampleLuma(prev); len(prevLum) == len(lum) {
+			var diff float64
+			for i := range lum {
+				diff += math.Abs(lum[i] - prevLum[i])
+			}
+			score += diff / n
+		}
+	}
+	return math.Min(score, 1)
+}
+
+// lumaStats 亮度的平均值和标准差
+func lumaStats(lum []float64) (float64, float64) {
+	var sum, sumSq float64
+	for _, l := range lum {
+		sum += l
+		sumSq += l * l
+	}
+	n := float64(len(lum))
+	mean := sum / n
+	return mean, math.Sqrt(math.Max(sumSq/n-mean*mean, 0))
+}
+
+// sampleLuma 在 32x32 网格上采样亮度（0~1）
+func sampleLuma(img image.Image) []float64 {
+	b := img.Bounds()
+	if b.Empty() {
+		return nil
+	}
+
+	const grid = 32
+	lum := make([]float64, 0, grid*grid)
+	for y := 0; y < grid; y++ {
+		for x := 0; x < grid; x++ {
+			px := b.Min.X + x*b.Dx()/grid
+			py := b.Min.Y + y*b.Dy()/grid
+			r, g, bl, _ := img.At(px, py).RGBA()
+			lum = append(lum, (0.299*float64(r)+0.587*float64(g)+0.114*float64(bl))/65535)
+		}
+	}
+	return lum
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateCoverCandidates(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "movie.mp4")
+	os.WriteFile(videoPath, []byte("candidates"), 0644)
+
+	candidates, err := GenerateCoverCandidates(context.Background(), videoPath, 3, 120, 4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 4 {
+		t.Fatalf("%d candidates, want 4", len(candidates))
+	}
+	if entries, _ := os.ReadDir(CandidateDir(3)); len(entries) != 5 {
+		t.Errorf("%d files in candidate dir, want 4 frames and the index", len(entries))
+	}
+
+	// 列出的评分和顺序与生成时一致（按场景挑选时评分包含与前一帧的差异）
+	if listed := ListCoverCandidates(3); !reflect.DeepEqual(listed, candidates) {
+		t.Errorf("ListCoverCandidates = %+v, want %+v", listed, candidates)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GenerateCoverCandidates(ctx, videoPath, 3, 120, 4, false); err != context.Canceled {
+		t.Errorf("cancelled generation error = %v", err)
+	}
+	RemoveCoverCandidates(3)
+}
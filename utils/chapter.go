@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"hidevideo/backend/config"
+)
+
+// 章节来源
+const (
+	ChapterEmbedded = "embedded" // 容器内嵌章节
+	ChapterUser     = "user"     // 用户创建的章节
+	ChapterScene    = "scene"    // 场景检测自动生成的章节
+)
+
+// ChapterDir 视频的章节缩略图目录
+func ChapterDir(videoID uint) string {
+	return filepath.Join(config.ChapterConfig.Dir, fmt.Sprintf("%d", videoID))
+}
+
+// ChapterThumbnailPath 指定时间点章节缩略图的路径（以毫秒命名，相同起点的章节共用）
+func ChapterThumbnailPath(videoID uint, start float64) string {
+	return filepath.Join(ChapterDir(videoID), fmt.Sprintf("%d.jpg", int64(math.Round(start*1000))))
+}
+
+// RemoveChapterFiles 删除视频的全部章节缩略图
+func RemoveChapterFiles(videoID uint) error {
+	return os.RemoveAll(ChapterDir(videoID))
+}
+
+// GenerateChapterThumbnail 截取章节起点的画面作为缩略图，已存在时直接返回
+func GenerateChapterThumbnail(videoPath string, videoID uint, start float64) (string, error) {
+	path := ChapterThumbnailPath(videoID, start)
+	if FileExists(path) {
+		return path, nil
+	}
+	if err := os.MkdirAll(ChapterDir(videoID), 0755); err != nil {
+		return "", err
+	}
+
+	opts := FrameOptions{Width: config.ChapterConfig.ThumbWidth, Height: config.ChapterConfig.ThumbHeight}
+	if err := Media.ExtractFrame(videoPath, start, path, opts); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
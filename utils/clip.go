@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hidevideo/backend/config"
+)
+
+// 片段导出格式
+const (
+	ClipCopy = "copy" // 复制音视频流，起点对齐到之前的关键帧
+	ClipMP4  = "mp4"  // 重新编码为 H.264/AAC，起止时间精确
+	ClipGIF  = "gif"
+	ClipWebP = "webp"
+)
+
+// ClipExt 导出格式对应的文件扩展名，不支持的格式返回空字符串
+func ClipExt(format string) string {
+	switch format {
+	case ClipCopy, ClipMP4:
+		return ".mp4"
+	case ClipGIF:
+		return ".gif"
+	case ClipWebP:
+		return ".webp"
+	}
+	return ""
+}
+
+// IsAnimatedClip 是否导出为无声动图
+func IsAnimatedClip(format string) bool {
+	return format == ClipGIF || format == ClipWebP
+}
+
+// ClipArgs 截取片段的 ffmpeg 参数，返回位于 -i 之前的定位参数和编码参数
+func ClipArgs(format string, start, length float64) ([]string, []string) {
+	inputArgs := []string{"-ss", fmt.Sprintf("%.3f", start)}
+	args := []string{"-t", fmt.Sprintf("%.3f", length)}
+
+	cfg := config.ClipConfig
+	switch format {
+	case ClipCopy:
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?", "-c", "copy",
+			"-avoid_negative_ts", "make_zero", "-movflags", "+faststart", "-f", "mp4")
+	case ClipGIF:
+		// 先生成调色板再套用，避免 GIF 默认调色板造成的色带
+		args = append(args, "-an", "-filter_complex",
+			fmt.Sprintf("[0:v:0]fps=%d,scale=%d:-2:flags=lanczos,split[a][b];[a]palettegen[p];[b][p]paletteuse", cfg.AnimatedFPS, cfg.AnimatedWidth),
+			"-loop", "0", "-f", "gif")
+	case ClipWebP:
+		args = append(args, "-map", "0:v:0", "-an",
+			"-vf", fmt.Sprintf("fps=%d,scale=%d:-2", cfg.AnimatedFPS, cfg.AnimatedWidth),
+			"-c:v", "libwebp", "-loop", "0", "-q:v", "70", "-f", "webp")
+	default:
+		args = append(args, "-map", "0:v:0", "-map", "0:a:0?",
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "20", "-pix_fmt", "yuv420p",
+			"-c:a", "aac", "-ac", "2", "-b:a", "192k", "-movflags", "+faststart", "-f", "mp4")
+	}
+	return inputArgs, args
+}
+
+// ClipFilename 生成片段文件名：未指定时使用「原文件名_起点-终点」，去掉路径分隔符并使用格式对应的扩展名
+func ClipFilename(name, videoFilename, format string, start, end float64) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		base := strings.TrimSuffix(videoFilename, filepath.Ext(videoFilename))
+		name = fmt.Sprintf("%s_%s-%s", base, clipTime(start), clipTime(end))
+	}
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if name == "" || name == "." || name == ".." {
+		name = "clip"
+	}
+	return name + ClipExt(format)
+}
+
+// clipTime 文件名中使用的时间，如 1h02m03s、02m03s
+func clipTime(seconds float64) string {
+	total := int(seconds)
+	h, m, s := total/3600, total%3600/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm%02ds", h, m, s)
+	}
+	return fmt.Sprintf("%02dm%02ds", m, s)
+}
+
+// ReservePath 独占创建空文件占用文件名，目标文件已存在时在文件名后追加序号，返回占用的路径
+// 同时导出的片段不会选中同一文件名，调用方随后将导出的文件重命名到该路径
+func ReservePath(path string) (string, error) {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	candidate := path
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return candidate, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+// ClipDownloadDir 供下载的片段的保存目录，每个导出任务一个子目录
+func ClipDownloadDir(jobID uint) string {
+	return filepath.Join(config.ClipConfig.Dir, fmt.Sprintf("%d", jobID))
+}
+
+// RemoveExpiredClips 删除超过保留时间的下载片段，返回删除的目录数
+func RemoveExpiredClips(retention time.Duration) int {
+	entries, err := os.ReadDir(config.ClipConfig.Dir)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < retention {
+			continue
+		}
+		if os.RemoveAll(filepath.Join(config.ClipConfig.Dir, entry.Name())) == nil {
+			count++
+		}
+	}
+	return count
+}
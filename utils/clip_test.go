@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hidevideo/backend/config"
+)
+
+func TestClipFilename(t *testing.T) {
+	tests := []struct {
+		name, video, format string
+		start, end          float64
+		want                string
+	}{
+		{"", "movie.mkv", ClipCopy, 65, 3725, "movie_01m05s-1h02m05s.mp4"},
+		{"", "movie.mkv", ClipGIF, 0, 9.9, "movie_00m00s-00m09s.gif"},
+		{" highlight ", "movie.mkv", ClipWebP, 0, 5, "highlight.webp"},
+		{"best.mov", "movie.mkv", ClipMP4, 0, 5, "best.mp4"},
+		{"dir/sub/name", "movie.mkv", ClipMP4, 0, 5, "dir_sub_name.mp4"},
+		{`a\b`, "movie.mkv", ClipMP4, 0, 5, "a_b.mp4"},
+		{"..", "movie.mkv", ClipMP4, 0, 5, "clip.mp4"},
+		{".mp4", "movie.mkv", ClipMP4, 0, 5, "clip.mp4"},
+	}
+	for _, tt := range tests {
+		if got := ClipFilename(tt.name, tt.video, tt.format, tt.start, tt.end); got != tt.want {
+			t.Errorf("ClipFilename(%q, %q, %q) = %q, want %q", tt.name, tt.video, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestReservePath(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "clip.mp4")
+	if got, err := ReservePath(path); err != nil || got != path {
+		t.Errorf("ReservePath = %q %v, want %q", got, err, path)
+	}
+	// 已占用的文件名不会再次返回
+	os.WriteFile(filepath.Join(dir, "clip (2).mp4"), nil, 0644)
+	for _, want := range []string{"clip (1).mp4", "clip (3).mp4"} {
+		if got, err := ReservePath(path); err != nil || got != filepath.Join(dir, want) {
+			t.Errorf("ReservePath = %q %v, want %s", got, err, want)
+		}
+	}
+}
+
+func TestRemoveExpiredClips(t *testing.T) {
+	expired := ClipDownloadDir(1)
+	fresh := ClipDownloadDir(2)
+	os.MkdirAll(expired, 0755)
+	os.MkdirAll(fresh, 0755)
+	old := time.Now().Add(-48 * time.Hour)
+	os.Chtimes(expired, old, old)
+
+	if n := RemoveExpiredClips(24 * time.Hour); n != 1 {
+		t.Errorf("RemoveExpiredClips = %d, want 1", n)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Error("expired clip dir kept")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("fresh clip dir removed")
+	}
+	os.RemoveAll(config.ClipConfig.Dir)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"image"
+	"math"
+	"os"
+)
+
+// 空白画面判定阈值（亮度范围 0~1）
+const (
+	blankDarkLuma   = 0.08 // 平均亮度低于该值视为黑屏
+	blankBrightLuma = 0.95 // 平均亮度高于该值视为白屏
+	blankMinScore   = 0.08 // 画面丰富程度（FrameScore）低于该值视为单色画面（如纯色标题卡）
+)
+
+// CoverResult 自动封面生成结果
+type CoverResult struct {
+	Path     string
+	Second   float64 // 实际截取的时间点
+	Fallback bool    // 指定时间点为空白画面，已改用其他画面
+	Blank    bool    // 所有尝试的画面都是空白画面
+}
+
+// IsBlankFrame 判断画面是否为黑屏、白屏或几乎单色
+func IsBlankFrame(img image.Image) bool {
+	lum := sampleLuma(img)
+	if len(lum) == 0 {
+		return true
+	}
+
+	mean, _ := lumaStats(lum)
+	return mean < blankDarkLuma || mean > blankBrightLuma || FrameScore(img, nil) < blankMinScore
+}
+
+// fallbackSeconds 指定时间点为空白画面时依次尝试的时间点
+func fallbackSeconds(second, duration float64) []float64 {
+	var list []float64
+	if duration <= 0 {
+		return []float64{second, second + 10, second + 30, second + 60}
+	}
+
+	for _, s := range []float64{second, second + 10, duration * 0.1, duration * 0.25, duration * 0.5, duration * 0.75} {
+		if s >= duration-1 {
+			continue
+		}
+		duplicate := false
+		for _, existing := range list {
+			if math.Abs(existing-s) < 1 {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// GenerateAutoCover 生成封面并检查画面，黑屏、白屏或单色画面时改用其他时间点，
+// 每个时间点使用 ffmpeg thumbnail 滤镜从随后的 100 帧中挑选最有代表性的一帧，duration 为 0 时自动获取视频时长
+func GenerateAutoCover(videoPath string, videoID uint, second, duration float64) (*CoverResult, error) {
+	if duration <= 0 {
+		info, err := GetVideoInfo(videoPath)
+		if err != nil {
+			return nil, err
+		}
+		duration = info.Duration
+	}
+	// 与 GenerateCover 一致，视频不足指定秒数时从开头截取
+	start := second
+	if duration < second {
+		start = 0
+	}
+
+	coverPath, err := extractCover(videoPath, videoID, start)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CoverResult{Path: coverPath, Second: start}
+	img, err := decodeJPEG(coverPath)
+	if err != nil || !IsBlankFrame(img) {
+		return result, nil
+	}
+
+	bestScore := FrameScore(img, nil)
+	tmpPath := coverPath + ".tmp.jpg"
+	defer os.Remove(tmpPath)
+
+	width, height := CoverSize()
+	for _, s := range fallbackSeconds(start, duration) {
+		opts := FrameOptions{Width: width, Height: height, Filter: "thumbnail=100"}
+		if err := Media.ExtractFrame(videoPath, s, tmpPath, opts); err != nil {
+			continue
+		}
+		candidate, err := decodeJPEG(tmpPath)
+		if err != nil {
+			continue
+		}
+
+		blank := IsBlankFrame(candidate)
+		if score := FrameScore(candidate, nil); !blank || score > bestScore {
+			// 文件名使用实际截取的时间点，生成多规格原图时按该时间点重新截取
+			path := AutoCoverPath(videoID, s)
+			if err := os.Rename(tmpPath, path); err != nil {
+				continue
+			}
+			if path != result.Path {
+				os.Remove(result.Path)
+				result.Path = path
+			}
+			bestScore = score
+			result.Second = s
+			result.Fallback = s != start
+		}
+		if !blank {
+			return result, nil
+		}
+	}
+
+	result.Blank = true
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// solidImage 单色画面
+func solidImage(shade uint8) image.Image {
+	img := image.NewGray(image.Rect(0, 0, 240, 140))
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	return img
+}
+
+// checkerImage 黑白相间的画面
+func checkerImage() image.Image {
+	img := image.NewGray(image.Rect(0, 0, 240, 140))
+	for y := 0; y < 140; y++ {
+		for x := 0; x < 240; x++ {
+			if (x/15+y/15)%2 == 0 {
+				img.SetGray(x, y, color.Gray{Y: 230})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 20})
+			}
+		}
+	}
+	return img
+}
+
+func TestIsBlankFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		img   image.Image
+		blank bool
+	}{
+		{"black", solidImage(0), true},
+		{"white", solidImage(255), true},
+		{"gray title card", solidImage(128), true},
+		{"empty", image.NewGray(image.Rect(0, 0, 0, 0)), true},
+		{"checkerboard", checkerImage(), false},
+	}
+	for _, tt := range tests {
+		if got := IsBlankFrame(tt.img); got != tt.blank {
+			t.Errorf("IsBlankFrame(%s) = %v, want %v", tt.name, got, tt.blank)
+		}
+	}
+}
+
+// sceneMediaTool 30 秒之前为单色画面，之后为有内容的画面
+type sceneMediaTool struct {
+	*FakeMediaTool
+}
+
+func (m sceneMediaTool) ExtractFrame(videoPath string, second float64, outPath string, opts FrameOptions) error {
+	if second < 30 {
+		return m.FakeMediaTool.ExtractFrame(videoPath, second, outPath, opts)
+	}
+	return encodeJPEG(outPath, checkerImage())
+}
+
+func TestGenerateAutoCover(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "movie.mp4")
+	os.WriteFile(videoPath, []byte("cover"), 0644)
+
+	// FakeMediaTool 的截帧都是单色画面，尝试所有时间点后标记为空白，仍使用指定时间点
+	fake := &FakeMediaTool{}
+	saved := Media
+	Media = fake
+	defer func() { Media = saved }()
+
+	result, err := GenerateAutoCover(videoPath, 1, 5, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Blank || result.Fallback || result.Second != 5 {
+		t.Errorf("solid frames not reported as blank at the requested second: %+v", result)
+	}
+	// 已传入时长，不再获取视频信息
+	for _, call := range fake.Calls() {
+		if strings.HasPrefix(call, "probe:") {
+			t.Errorf("video probed again: %v", fake.Calls())
+			break
+		}
+	}
+	if _, err := os.Stat(result.Path); err != nil {
+		t.Errorf("cover %s not written: %v", result.Path, err)
+	}
+
+	// 指定时间点为空白画面时改用其他时间点，文件名使用实际截取的时间点
+	Media = sceneMediaTool{&FakeMediaTool{}}
+
+	result, err = GenerateAutoCover(videoPath, 2, 5, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Fallback || result.Blank || result.Second != 30 {
+		t.Errorf("unexpected fallback result: %+v", result)
+	}
+	if result.Path != AutoCoverPath(2, result.Second) {
+		t.Errorf("cover path = %s, want %s", result.Path, AutoCoverPath(2, result.Second))
+	}
+	if _, err := os.Stat(result.Path); err != nil {
+		t.Errorf("fallback cover not written: %v", err)
+	}
+	if _, err := os.Stat(AutoCoverPath(2, 5)); !os.IsNotExist(err) {
+		t.Error("blank cover at the requested second not removed")
+	}
+}
@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"hidevideo/backend/models"
+)
+
+// ProbeData ffprobe 输出的 JSON 结构
+type ProbeData struct {
+	Streams  []ProbeStream  `json:"streams"`
+	Format   ProbeFormat    `json:"format"`
+	Chapters []ProbeChapter `json:"chapters"`
+}
+
+// ProbeStream ffprobe 流信息
+type ProbeStream struct {
+	Index          int               `json:"index"`
+	CodecName      string            `json:"codec_name"`
+	CodecType      string            `json:"codec_type"`
+	Profile        string            `json:"profile"`
+	Width          int               `json:"width"`
+	Height         int               `json:"height"`
+	PixFmt         string            `json:"pix_fmt"`
+	ColorTransfer  string            `json:"color_transfer"`
+	ColorPrimaries string            `json:"color_primaries"`
+	RFrameRate     string            `json:"r_frame_rate"`
+	AvgFrameRate   string            `json:"avg_frame_rate"`
+	SampleRate     string            `json:"sample_rate"`
+	Channels       int               `json:"channels"`
+	ChannelLayout  string            `json:"channel_layout"`
+	BitRate        string            `json:"bit_rate"`
+	Duration       string            `json:"duration"`
+	Disposition    map[string]int    `json:"disposition"`
+	Tags           map[string]string `json:"tags"`
+	SideDataList   []struct {
+		SideDataType string `json:"side_data_type"`
+	} `json:"side_data_list"`
+}
+
+// ProbeFormat ffprobe 容器信息
+type ProbeFormat struct {
+	FormatName     string            `json:"format_name"`
+	FormatLongName string            `json:"format_long_name"`
+	Duration       string            `json:"duration"`
+	Size           string            `json:"size"`
+	BitRate        string            `json:"bit_rate"`
+	Tags           map[string]string `json:"tags"`
+}
+
+// ProbeChapter ffprobe 章节信息
+type ProbeChapter struct {
+	ID        int64             `json:"id"`
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// ProbeVideo 获取完整的媒体信息
+func ProbeVideo(videoPath string) (*ProbeData, error) {
+	return Media.Probe(videoPath)
+}
+
+// runProbe 调用 ffprobe 并解析 JSON 输出
+func runProbe(ffprobePath, videoPath string) (*ProbeData, error) {
+	cmd := exec.Command(ffprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		"-show_chapters",
+		videoPath,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe error: %v", err)
+	}
+
+	var data ProbeData
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("ffprobe 输出解析失败: %v", err)
+	}
+	return &data, nil
+}
+
+// FirstVideoStream 获取第一个视频流（忽略内嵌封面图片）
+func (p *ProbeData) FirstVideoStream() *ProbeStream {
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		if s.CodecType == "video" && s.Disposition["attached_pic"] == 0 {
+			return s
+		}
+	}
+	return nil
+}
+
+// Duration 获取时长（秒），容器没有时长时使用视频流时长
+func (p *ProbeData) Duration() float64 {
+	if d := parseFloat(p.Format.Duration); d > 0 {
+		return d
+	}
+	if s := p.FirstVideoStream(); s != nil {
+		return parseFloat(s.Duration)
+	}
+	return 0
+}
+
+// Tag 不区分大小写获取标签值
+func (s *ProbeStream) Tag(key string) string {
+	return lookupTag(s.Tags, key)
+}
+
+// HDR 根据传输特性和附加数据判断 HDR 类型，SDR 返回空字符串
+func (s *ProbeStream) HDR() string {
+	for _, sd := range s.SideDataList {
+		if strings.Contains(sd.SideDataType, "DOVI") {
+			return "DolbyVision"
+		}
+	}
+	switch s.ColorTransfer {
+	case "smpte2084":
+		return "HDR10"
+	case "arib-std-b67":
+		return "HLG"
+	}
+	return ""
+}
+
+// FrameRate 解析帧率，如 "30000/1001"
+func (s *ProbeStream) FrameRate() float64 {
+	if r := parseRational(s.AvgFrameRate); r > 0 {
+		return r
+	}
+	return parseRational(s.RFrameRate)
+}
+
+// StreamRecords 转换为数据库中的流记录
+func (p *ProbeData) StreamRecords() []models.VideoStream {
+	var streams []models.VideoStream
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		record := models.VideoStream{
+			StreamIndex: s.Index,
+			Type:        s.CodecType,
+			Codec:       s.CodecName,
+			Profile:     s.Profile,
+			Language:    s.Tag("language"),
+			Title:       s.Tag("title"),
+			Default:     s.Disposition["default"] == 1,
+			Forced:      s.Disposition["forced"] == 1,
+			AttachedPic: s.Disposition["attached_pic"] == 1,
+			BitRate:     parseInt(s.BitRate),
+		}
+		switch s.CodecType {
+		case "video":
+			record.Width = s.Width
+			record.Height = s.Height
+			record.FrameRate = s.FrameRate()
+			record.PixelFormat = s.PixFmt
+			record.ColorTransfer = s.ColorTransfer
+			record.ColorPrimaries = s.ColorPrimaries
+			record.HDR = s.HDR()
+		case "audio":
+			record.Channels = s.Channels
+			record.ChannelLayout = s.ChannelLayout
+			record.SampleRate = int(parseInt(s.SampleRate))
+		}
+		streams = append(streams, record)
+	}
+	return streams
+}
+
+// ChapterRecords 转换为数据库中的章节记录，没有标题的章节按序号命名
+func (p *ProbeData) ChapterRecords() []models.Chapter {
+	var chapters []models.Chapter
+	for i, ch := range p.Chapters {
+		title := lookupTag(ch.Tags, "title")
+		if title == "" {
+			title = fmt.Sprintf("章节 %d", i+1)
+		}
+		chapters = append(chapters, models.Chapter{
+			Source: ChapterEmbedded,
+			Title:  title,
+			Start:  parseFloat(ch.StartTime),
+			End:    parseFloat(ch.EndTime),
+		})
+	}
+	return chapters
+}
+
+// FormatRecord 转换为数据库中的容器记录
+func (p *ProbeData) FormatRecord() *models.VideoFormat {
+	format := &models.VideoFormat{
+		FormatName:     p.Format.FormatName,
+		FormatLongName: p.Format.FormatLongName,
+		Duration:       p.Duration(),
+		BitRate:        parseInt(p.Format.BitRate),
+		Size:           parseInt(p.Format.Size),
+		Title:          lookupTag(p.Format.Tags, "title"),
+	}
+	if len(p.Format.Tags) > 0 {
+		if data, err := json.Marshal(p.Format.Tags); err == nil {
+			format.Tags = models.JSONText(data)
+		}
+	}
+	return format
+}
+
+// lookupTag 不区分大小写查找标签
+func lookupTag(tags map[string]string, key string) string {
+	if v, ok := tags[key]; ok {
+		return v
+	}
+	for k, v := range tags {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseFloat 解析数字字符串，失败返回 0
+func parseFloat(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// parseInt 解析整数字符串，失败返回 0
+func parseInt(s string) int64 {
+	i, _ := strconv.ParseInt(s, 10, 64)
+	return i
+}
+
+// parseRational 解析分数形式的数值
+func parseRational(s string) float64 {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return parseFloat(s)
+	}
+	num, den := parseFloat(parts[0]), parseFloat(parts[1])
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
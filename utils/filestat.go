@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// FileStat 文件指纹信息，用于判断文件是否发生变化
+type FileStat struct {
+	Size    int64
+	ModTime time.Time
+	Inode   uint64
+}
+
+// StatFile 获取文件的大小、修改时间和 inode
+func StatFile(path string) (*FileStat, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStat{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Inode:   fileInode(info),
+	}, nil
+}
+
+// partialHashChunk 计算内容指纹时读取的头尾长度
+const partialHashChunk = 1 << 20
+
+// PartialHash 计算文件内容指纹（文件大小 + 头尾各 1MB 的 SHA1），用于识别移动或重命名的文件
+func PartialHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := info.Size()
+
+	h := sha1.New()
+	fmt.Fprintf(h, "%d:", size)
+
+	if size <= 2*partialHashChunk {
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	if _, err := io.CopyN(h, f, partialHashChunk); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(size-partialHashChunk, io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.CopyN(h, f, partialHashChunk); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hidevideo/backend/config"
+)
+
+// iconPathWidth 保存在 icon_path 中的图标宽度（中尺寸）
+const iconPathWidth = 80
+
+// iconName 图标规格在文件名中的名称，如 icon-medium@2x 为 medium@2x
+func iconName(profile config.ImageProfile) string {
+	return strings.TrimPrefix(profile.Name, "icon-")
+}
+
+// IconPath 视频指定规格图标的路径
+func IconPath(videoID uint, profile config.ImageProfile) string {
+	return filepath.Join(config.IconConfig.Dir, fmt.Sprintf("icon_%d_%s.%s", videoID, iconName(profile), profile.Format))
+}
+
+// IconFiles 视频全部规格图标的路径
+func IconFiles(videoID uint) []string {
+	profiles := ImageProfiles("icon")
+	files := make([]string, 0, len(profiles))
+	for _, p := range profiles {
+		files = append(files, IconPath(videoID, p))
+	}
+	return files
+}
+
+// RemoveIcons 删除视频的全部图标
+func RemoveIcons(videoID uint) {
+	for _, path := range IconFiles(videoID) {
+		os.Remove(path)
+	}
+}
+
+// GenerateIcons 按图片配置中的 icon 规格（包括高分屏规格）生成图标，返回中尺寸图标路径
+func GenerateIcons(sourcePath string, videoID uint) (string, error) {
+	profile, ok := BestImageProfile("icon", iconPathWidth)
+	if !ok {
+		return "", fmt.Errorf("没有配置图标规格")
+	}
+	if err := os.MkdirAll(config.IconConfig.Dir, 0755); err != nil {
+		return "", fmt.Errorf("创建图标目录失败")
+	}
+
+	for _, p := range ImageProfiles("icon") {
+		opts := ImageOptions{Width: p.Width, Height: p.Height, Pad: p.Pad, Format: p.Format, Quality: p.Quality}
+		err := writeFileAtomic(IconPath(videoID, p), func(tmp string) error {
+			return Media.ResizeImage(sourcePath, tmp, opts)
+		})
+		if err != nil {
+			return "", fmt.Errorf("%s 图标生成失败: %v", iconName(p), err)
+		}
+	}
+	return IconPath(videoID, profile), nil
+}
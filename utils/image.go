@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/models"
+)
+
+// coverSecondPattern 自动生成的封面文件名 cover_<id>_<秒>.jpg
+var coverSecondPattern = regexp.MustCompile(`^cover_\d+_(\d+)\.jpg$`)
+
+// AutoCoverPath 从视频截取的封面路径，文件名中的时间点用于重新截取高分辨率原图
+func AutoCoverPath(videoID uint, second float64) string {
+	return filepath.Join(config.ServerConfig.StaticPath, fmt.Sprintf("cover_%d_%d.jpg", videoID, int(second)))
+}
+
+// ImageDir 视频的多规格图片目录
+func ImageDir(videoID uint) string {
+	return filepath.Join(config.ImageConfig.Dir, fmt.Sprintf("%d", videoID))
+}
+
+// ImageSourcePath 生成各规格图片所用的原图
+func ImageSourcePath(videoID uint) string {
+	return filepath.Join(ImageDir(videoID), "source.jpg")
+}
+
+// ImageVariantPath 指定规格图片的路径
+func ImageVariantPath(videoID uint, profile config.ImageProfile) string {
+	return filepath.Join(ImageDir(videoID), profile.Name+"."+profile.Format)
+}
+
+// RemoveImageVariants 删除视频的原图和全部规格图片（封面变化后调用）
+func RemoveImageVariants(videoID uint) error {
+	return os.RemoveAll(ImageDir(videoID))
+}
+
+// ImageProfiles 返回指定类型的规格，按配置顺序排列
+func ImageProfiles(kind string) []config.ImageProfile {
+	var list []config.ImageProfile
+	for _, p := range config.ImageConfig.Profiles {
+		if kind == "" || p.Kind == kind {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// CoverSize 保存在 cover_path 中的封面尺寸，使用最小的 cover 规格
+func CoverSize() (int, int) {
+	if profiles := ImageProfiles("cover"); len(profiles) > 0 {
+		return profiles[0].Width, profiles[0].Height
+	}
+	return 240, 140
+}
+
+// BestImageProfile 返回宽度不小于 width 的最小规格，都不满足时返回最大的规格
+func BestImageProfile(kind string, width int) (config.ImageProfile, bool) {
+	profiles := ImageProfiles(kind)
+	if len(profiles) == 0 {
+		return config.ImageProfile{}, false
+	}
+
+	best, largest := -1, 0
+	for i, p := range profiles {
+		if p.Width >= width && (best < 0 || p.Width < profiles[best].Width) {
+			best = i
+		}
+		if p.Width > profiles[largest].Width {
+			largest = i
+		}
+	}
+	if best < 0 {
+		best = largest
+	}
+	return profiles[best], true
+}
+
+// EnsureImageSource 准备原图：自动封面按封面时间点重新截取高分辨率画面，上传的封面直接复制
+func EnsureImageSource(video *models.Video) (string, error) {
+	source := ImageSourcePath(video.ID)
+	if FileExists(source) {
+		return source, nil
+	}
+	if video.CoverPath == "" || !FileExists(video.CoverPath) {
+		return "", fmt.Errorf("视频没有封面")
+	}
+	if err := os.MkdirAll(ImageDir(video.ID), 0755); err != nil {
+		return "", err
+	}
+
+	if m := coverSecondPattern.FindStringSubmatch(filepath.Base(video.CoverPath)); m != nil && FileExists(video.Filepath) {
+		second, _ := strconv.ParseFloat(m[1], 64)
+		opts := FrameOptions{Width: config.ImageConfig.SourceWidth, Height: config.ImageConfig.SourceHeight}
+		err := writeFileAtomic(source, func(tmp string) error {
+			return Media.ExtractFrame(video.Filepath, second, tmp, opts)
+		})
+		if err == nil {
+			return source, nil
+		}
+	}
+
+	data, err := os.ReadFile(video.CoverPath)
+	if err != nil {
+		return "", err
+	}
+	err = writeFileAtomic(source, func(tmp string) error {
+		return os.WriteFile(tmp, data, 0644)
+	})
+	if err != nil {
+		return "", err
+	}
+	return source, nil
+}
+
+// RenderImageVariant 从原图生成指定规格的图片，force 为 false 时已存在的图片不重新生成
+func RenderImageVariant(video *models.Video, profile config.ImageProfile, force bool) (string, error) {
+	dst := ImageVariantPath(video.ID, profile)
+	if !force && FileExists(dst) {
+		return dst, nil
+	}
+
+	source, err := EnsureImageSource(video)
+	if err != nil {
+		return "", err
+	}
+
+	opts := ImageOptions{
+		Width:   profile.Width,
+		Height:  profile.Height,
+		Pad:     profile.Pad,
+		Format:  profile.Format,
+		Quality: profile.Quality,
+	}
+	err = writeFileAtomic(dst, func(tmp string) error {
+		return Media.ResizeImage(source, tmp, opts)
+	})
+	if err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// writeFileAtomic 由 write 写入同一目录下的临时文件（扩展名与 dst 相同），成功后重命名为 dst，
+// 同时读取该文件的请求不会得到写了一半的内容
+func writeFileAtomic(dst string, write func(tmp string) error) error {
+	ext := filepath.Ext(dst)
+	f, err := os.CreateTemp(filepath.Dir(dst), strings.TrimSuffix(filepath.Base(dst), ext)+".*.tmp"+ext)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	f.Close()
+
+	if err := write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
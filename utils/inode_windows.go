@@ -0,0 +1,10 @@
+//go:build windows
+
+package utils
+
+import "os"
+
+// fileInode Windows 下没有 inode，始终返回 0
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
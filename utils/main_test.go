@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"hidevideo/backend/config"
+)
+
+// TestMain 封面等生成文件写入临时目录，媒体操作使用 FakeMediaTool
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "hidevideo-utils-")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	config.ServerConfig.StaticPath = filepath.Join(dir, "covers")
+	config.ClipConfig.Dir = filepath.Join(dir, "clips")
+	config.SpriteConfig.Dir = filepath.Join(dir, "sprites")
+	config.SubtitleConfig.Dir = filepath.Join(dir, "subtitles")
+	Media = &FakeMediaTool{}
+
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	// config 包初始化时在当前目录创建的空数据目录
+	os.Remove("data/covers")
+	os.Remove("data")
+	os.Exit(code)
+}
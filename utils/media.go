@@ -0,0 +1,215 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"hidevideo/backend/config"
+)
+
+// MediaTool 媒体处理工具，默认由 ffmpeg/ffprobe 实现，测试时可替换为 FakeMediaTool
+type MediaTool interface {
+	// Probe 获取媒体文件的完整信息
+	Probe(path string) (*ProbeData, error)
+	// ExtractFrame 截取指定时间点的一帧并保存为图片
+	ExtractFrame(videoPath string, second float64, outPath string, opts FrameOptions) error
+	// Transcode 执行转码/封装，ctx 取消时终止进程
+	Transcode(ctx context.Context, opts TranscodeOptions) error
+	// ResizeImage 缩放图片
+	ResizeImage(src, dst string, opts ImageOptions) error
+}
+
+// FrameOptions 截帧参数
+type FrameOptions struct {
+	Width   int    // 最大宽度，0 表示不缩放
+	Height  int    // 最大高度，0 表示不缩放
+	Filter  string // 额外的视频滤镜，追加在缩放之前
+	Quality int    // JPEG 质量（2-31，越小越好），0 使用默认值
+
+	Context context.Context // 结束时终止 ffmpeg，为空时不限制
+}
+
+// ImageOptions 图片缩放参数
+type ImageOptions struct {
+	Width   int
+	Height  int
+	Pad     bool   // 是否填充为固定尺寸
+	Format  string // 输出格式（jpg、png、webp、avif），为空时按输出文件扩展名判断
+	Quality int    // 输出质量 1~100，0 使用默认值
+}
+
+// TranscodeOptions 转码参数
+type TranscodeOptions struct {
+	InputArgs []string  // 位于 -i 之前的参数，如 -ss
+	Input     string    // 输入文件
+	Args      []string  // 位于输入与输出之间的编码参数
+	Output    string    // 输出文件，为空时写入 Stdout
+	Stdout    io.Writer // 输出为管道时的写入目标
+	Stderr    io.Writer // ffmpeg 日志输出，可为空
+}
+
+// Media 当前使用的媒体处理工具
+var Media MediaTool = &FFmpegTool{}
+
+// FFmpegTool 基于 ffmpeg/ffprobe 命令行的实现
+type FFmpegTool struct {
+	once        sync.Once
+	ffmpegPath  string
+	ffprobePath string
+}
+
+// candidateDirs 未配置路径且 PATH 中找不到时依次尝试的目录
+var candidateDirs = []string{"/usr/bin", "/usr/local/bin", "/opt/homebrew/bin"}
+
+// resolveBinary 查找可执行文件，优先使用配置的路径
+func resolveBinary(configured, name string) string {
+	if configured != "" {
+		return configured
+	}
+	if p, err := exec.LookPath(name); err == nil {
+		return p
+	}
+	for _, dir := range candidateDirs {
+		p := dir + "/" + name
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return name
+}
+
+// binaries 返回 ffmpeg 与 ffprobe 路径
+func (t *FFmpegTool) binaries() (string, string) {
+	t.once.Do(func() {
+		t.ffmpegPath = resolveBinary(config.MediaConfig.FFmpegPath, "ffmpeg")
+		t.ffprobePath = resolveBinary(config.MediaConfig.FFprobePath, "ffprobe")
+	})
+	return t.ffmpegPath, t.ffprobePath
+}
+
+// FFmpegPath ffmpeg 可执行文件路径
+func (t *FFmpegTool) FFmpegPath() string {
+	p, _ := t.binaries()
+	return p
+}
+
+// FFprobePath ffprobe 可执行文件路径
+func (t *FFmpegTool) FFprobePath() string {
+	_, p := t.binaries()
+	return p
+}
+
+// Probe 调用 ffprobe 获取媒体信息
+func (t *FFmpegTool) Probe(path string) (*ProbeData, error) {
+	return runProbe(t.FFprobePath(), path)
+}
+
+// ExtractFrame 调用 ffmpeg 截取一帧
+func (t *FFmpegTool) ExtractFrame(videoPath string, second float64, outPath string, opts FrameOptions) error {
+	vf := opts.Filter
+	if opts.Width > 0 && opts.Height > 0 {
+		scale := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", opts.Width, opts.Height)
+		if vf != "" {
+			vf += "," + scale
+		} else {
+			vf = scale
+		}
+	}
+	quality := opts.Quality
+	if quality <= 0 {
+		quality = 2
+	}
+
+	args := []string{"-y", "-ss", fmt.Sprintf("%.2f", second), "-i", videoPath, "-vframes", "1"}
+	if vf != "" {
+		args = append(args, "-vf", vf)
+	}
+	args = append(args, "-q:v", fmt.Sprintf("%d", quality), outPath)
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := exec.CommandContext(ctx, t.FFmpegPath(), args...).Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg error: %v", err)
+	}
+	return nil
+}
+
+// Transcode 调用 ffmpeg 转码
+func (t *FFmpegTool) Transcode(ctx context.Context, opts TranscodeOptions) error {
+	args := append([]string{}, opts.InputArgs...)
+	args = append(args, "-i", opts.Input)
+	args = append(args, opts.Args...)
+	if opts.Output != "" {
+		args = append(args, "-y", opts.Output)
+	} else {
+		args = append(args, "pipe:1")
+	}
+
+	cmd := exec.CommandContext(ctx, t.FFmpegPath(), args...)
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	if err := cmd.Run(); err != nil {
+		// 被取消时返回 ctx 的错误，调用方可以区分取消和转码失败
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg error: %v", err)
+	}
+	return nil
+}
+
+// ResizeImage 调用 ffmpeg 缩放图片
+func (t *FFmpegTool) ResizeImage(src, dst string, opts ImageOptions) error {
+	vf := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", opts.Width, opts.Height)
+	if opts.Pad {
+		vf += fmt.Sprintf(",pad=%d:%d:(ow-iw)/2:(oh-ih)/2:black", opts.Width, opts.Height)
+	}
+
+	args := []string{"-i", src, "-vf", vf, "-frames:v", "1"}
+	args = append(args, imageEncodeArgs(opts.Format, opts.Quality)...)
+	args = append(args, "-y", dst)
+
+	if err := exec.Command(t.FFmpegPath(), args...).Run(); err != nil {
+		return fmt.Errorf("ffmpeg error: %v", err)
+	}
+	return nil
+}
+
+// imageEncodeArgs 按输出格式将 1~100 的质量转换为对应编码器的参数
+func imageEncodeArgs(format string, quality int) []string {
+	switch strings.ToLower(format) {
+	case "jpg", "jpeg":
+		if quality <= 0 {
+			return []string{"-f", "image2", "-c:v", "mjpeg"}
+		}
+		// mjpeg 的 -q:v 范围为 2（最好）~31（最差）
+		return []string{"-f", "image2", "-c:v", "mjpeg", "-q:v", fmt.Sprintf("%d", 2+(100-quality)*29/100)}
+	case "png":
+		return []string{"-f", "image2", "-c:v", "png"}
+	case "webp":
+		if quality <= 0 {
+			quality = 80
+		}
+		return []string{"-f", "webp", "-c:v", "libwebp", "-quality", fmt.Sprintf("%d", quality)}
+	case "avif":
+		if quality <= 0 {
+			quality = 60
+		}
+		// libaom 的 crf 范围为 0（无损）~63
+		return []string{"-f", "avif", "-c:v", "libaom-av1", "-still-picture", "1", "-crf", fmt.Sprintf("%d", (100-quality)*63/100)}
+	}
+	if quality > 0 {
+		return []string{"-q:v", fmt.Sprintf("%d", 2+(100-quality)*29/100)}
+	}
+	return nil
+}
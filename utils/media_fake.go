@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"sync"
+)
+
+// FakeMediaTool 确定性的媒体工具实现，不依赖 ffmpeg，供测试使用
+// 所有文件都被视为 2 分钟的 1920x1080 H.264/AAC 视频，截帧为纯色图片，转码结果为固定内容
+type FakeMediaTool struct {
+	// ProbeData 非空时 Probe 始终返回该数据
+	ProbeData *ProbeData
+	// Err 非空时所有操作都返回该错误
+	Err error
+
+	mu    sync.Mutex
+	calls []string
+}
+
+// Calls 返回已调用的操作记录，格式为 "方法名:路径"
+func (f *FakeMediaTool) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.calls...)
+}
+
+// record 记录一次调用
+func (f *FakeMediaTool) record(method, path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, method+":"+path)
+}
+
+// Probe 返回固定的媒体信息，文件不存在时返回错误
+func (f *FakeMediaTool) Probe(path string) (*ProbeData, error) {
+	f.record("probe", path)
+	if f.Err != nil {
+		return nil, f.Err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if f.ProbeData != nil {
+		return f.ProbeData, nil
+	}
+
+	return &ProbeData{
+		Streams: []ProbeStream{
+			{
+				Index:        0,
+				CodecName:    "h264",
+				CodecType:    "video",
+				Profile:      "High",
+				Width:        1920,
+				Height:       1080,
+				PixFmt:       "yuv420p",
+				AvgFrameRate: "25/1",
+				Disposition:  map[string]int{"default": 1},
+			},
+			{
+				Index:         1,
+				CodecName:     "aac",
+				CodecType:     "audio",
+				Channels:      2,
+				ChannelLayout: "stereo",
+				SampleRate:    "48000",
+				Disposition:   map[string]int{"default": 1},
+				Tags:          map[string]string{"language": "und"},
+			},
+		},
+		Format: ProbeFormat{
+			FormatName: "mov,mp4,m4a,3gp,3g2,mj2",
+			Duration:   "120.000000",
+			Size:       fmt.Sprintf("%d", info.Size()),
+			BitRate:    "1000000",
+		},
+	}, nil
+}
+
+// ExtractFrame 写入纯色的 JPEG 图片，尺寸为 opts 指定的最大尺寸（默认 320x180）
+func (f *FakeMediaTool) ExtractFrame(videoPath string, second float64, outPath string, opts FrameOptions) error {
+	f.record("frame", videoPath)
+	if f.Err != nil {
+		return f.Err
+	}
+	if opts.Context != nil && opts.Context.Err() != nil {
+		return opts.Context.Err()
+	}
+	if _, err := os.Stat(videoPath); err != nil {
+		return err
+	}
+
+	width, height := opts.Width, opts.Height
+	if width <= 0 || height <= 0 {
+		width, height = 320, 180
+	}
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	shade := uint8(int(second) % 256)
+	for i := range img.Pix {
+		img.Pix[i] = shade
+	}
+	return encodeJPEG(outPath, img)
+}
+
+// Transcode 写入固定内容的转码结果
+func (f *FakeMediaTool) Transcode(ctx context.Context, opts TranscodeOptions) error {
+	f.record("transcode", opts.Input)
+	if f.Err != nil {
+		return f.Err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data := []byte("FAKE-TRANSCODE " + opts.Input)
+	if opts.Output != "" {
+		return os.WriteFile(opts.Output, data, 0644)
+	}
+	if opts.Stdout != nil {
+		_, err := opts.Stdout.Write(data)
+		return err
+	}
+	return nil
+}
+
+// ResizeImage 复制原图内容
+func (f *FakeMediaTool) ResizeImage(src, dst string, opts ImageOptions) error {
+	f.record("resize", src)
+	if f.Err != nil {
+		return f.Err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, 0644)
+}
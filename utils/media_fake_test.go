@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFakeMediaTool(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "movie.mkv")
+	os.WriteFile(input, []byte("fake"), 0644)
+	fake := &FakeMediaTool{}
+
+	data, err := fake.Probe(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Format.Size != "4" || len(data.Streams) != 2 {
+		t.Errorf("unexpected probe data: %+v", data)
+	}
+	if _, err := fake.Probe(filepath.Join(dir, "missing.mkv")); err == nil {
+		t.Error("probing a missing file succeeded")
+	}
+
+	frame := filepath.Join(dir, "frame.jpg")
+	if err := fake.ExtractFrame(input, 12, frame, FrameOptions{Width: 64, Height: 36}); err != nil {
+		t.Fatal(err)
+	}
+	img, err := decodeJPEG(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 36 {
+		t.Errorf("frame size = %v, want 64x36", b)
+	}
+
+	output := filepath.Join(dir, "out.mp4")
+	if err := fake.Transcode(context.Background(), TranscodeOptions{Input: input, Output: output}); err != nil {
+		t.Fatal(err)
+	}
+	var stdout bytes.Buffer
+	fake.Transcode(context.Background(), TranscodeOptions{Input: input, Stdout: &stdout})
+	if data, _ := os.ReadFile(output); string(data) != "FAKE-TRANSCODE "+input || stdout.String() != string(data) {
+		t.Errorf("transcode output = %q, stdout = %q", data, stdout.String())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := fake.Transcode(ctx, TranscodeOptions{Input: input, Output: output}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled transcode error = %v", err)
+	}
+
+	if n := len(fake.Calls()); n != 6 {
+		t.Errorf("%d calls recorded, want 6: %v", n, fake.Calls())
+	}
+
+	fake = &FakeMediaTool{Err: errors.New("broken")}
+	if err := fake.ResizeImage(frame, filepath.Join(dir, "small.jpg"), ImageOptions{}); err == nil {
+		t.Error("Err not returned")
+	}
+}
+
+func TestGetVideoInfoWithFakeMediaTool(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "movie.mp4")
+	os.WriteFile(input, []byte("fake"), 0644)
+
+	info, err := GetVideoInfo(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Duration != 120 || info.Width != 1920 || info.Height != 1080 || info.Codec != "h264" {
+		t.Errorf("unexpected video info: %+v", info)
+	}
+}
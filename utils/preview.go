@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hidevideo/backend/config"
+)
+
+// previewStarts 在视频 5%~95% 范围内均匀选取片段起点，视频较短时减少片段数
+func previewStarts(duration, length float64, segments int) []float64 {
+	if duration <= length {
+		return []float64{0}
+	}
+	if n := int(duration / (length * 2)); n < segments {
+		segments = n
+	}
+	if segments < 1 {
+		segments = 1
+	}
+
+	begin, span := duration*0.05, duration*0.9-length
+	if span < 0 {
+		begin, span = 0, duration-length
+	}
+
+	starts := make([]float64, segments)
+	for i := range starts {
+		if segments == 1 {
+			starts[i] = begin + span/2
+		} else {
+			starts[i] = begin + span*float64(i)/float64(segments-1)
+		}
+	}
+	return starts
+}
+
+// GeneratePreview 从视频中截取若干短片段拼接为静音循环预览，保存在封面目录并返回文件路径
+// duration 为 0 时自动获取视频时长，run 执行 ffmpeg 转码，由调用方控制并发
+func GeneratePreview(ctx context.Context, videoPath string, videoID uint, duration float64, run func(context.Context, TranscodeOptions) error) (string, error) {
+	if duration <= 0 {
+		info, err := GetVideoInfo(videoPath)
+		if err != nil {
+			return "", err
+		}
+		duration = info.Duration
+	}
+	if duration <= 0 {
+		return "", fmt.Errorf("无法获取视频时长")
+	}
+
+	cfg := config.PreviewConfig
+	length := cfg.SegmentLength
+	if length <= 0 {
+		length = 1.5
+	}
+	length = math.Min(length, duration)
+	starts := previewStarts(duration, length, cfg.Segments)
+
+	format := strings.ToLower(cfg.Format)
+	if format != "webp" {
+		format = "mp4"
+	}
+
+	coverDir := config.ServerConfig.StaticPath
+	if err := os.MkdirAll(coverDir, 0755); err != nil {
+		return "", err
+	}
+	previewPath := filepath.Join(coverDir, fmt.Sprintf("preview_%d.%s", videoID, format))
+	tmp := previewPath + ".tmp"
+
+	// 每个片段作为一个输入，只解码需要的部分
+	inputArgs := []string{"-ss", fmt.Sprintf("%.3f", starts[0]), "-t", fmt.Sprintf("%.3f", length)}
+	var args []string
+	var filter, concat strings.Builder
+	for i, start := range starts {
+		if i > 0 {
+			args = append(args, "-ss", fmt.Sprintf("%.3f", start), "-t", fmt.Sprintf("%.3f", length), "-i", videoPath)
+		}
+		fmt.Fprintf(&filter, "[%d:v:0]fps=%d,scale=%d:-2,setsar=1,setpts=PTS-STARTPTS[v%d];", i, cfg.FPS, cfg.Width, i)
+		fmt.Fprintf(&concat, "[v%d]", i)
+	}
+	fmt.Fprintf(&filter, "%sconcat=n=%d:v=1:a=0[out]", concat.String(), len(starts))
+
+	args = append(args, "-filter_complex", filter.String(), "-map", "[out]", "-an")
+	if format == "webp" {
+		args = append(args, "-c:v", "libwebp", "-loop", "0", "-q:v", "60", "-f", "webp")
+	} else {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "28",
+			"-pix_fmt", "yuv420p", "-movflags", "+faststart", "-f", "mp4")
+	}
+
+	err := run(ctx, TranscodeOptions{
+		InputArgs: inputArgs,
+		Input:     videoPath,
+		Args:      args,
+		Output:    tmp,
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, previewPath); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return previewPath, nil
+}
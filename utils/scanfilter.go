@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ExtraVideoExtensions 可按库额外启用的视频扩展名
+var ExtraVideoExtensions = []string{".ts", ".m2ts", ".mts", ".mpg", ".mpeg", ".3gp", ".rmvb", ".rm", ".vob"}
+
+// builtinExcludeDirs 始终跳过的目录（回收站、NAS 缩略图目录、样片目录等），不区分大小写
+var builtinExcludeDirs = []string{"@eadir", "#recycle", "#snapshot", "$recycle.bin", "system volume information", "lost+found", "sample", "samples"}
+
+// ScanOptions 视频库扫描选项
+type ScanOptions struct {
+	// ExcludePatterns 排除规则，默认按 glob 匹配文件/目录名或相对库根目录的路径，
+	// 以 "re:" 开头时按正则匹配相对路径（路径分隔符统一为 /）
+	ExcludePatterns []string
+	MinFileSize     int64
+	FollowSymlinks  bool
+	ExtraExtensions []string
+}
+
+// ScanFilter 根据扫描选项过滤文件
+type ScanFilter struct {
+	globs          []string
+	regexps        []*regexp.Regexp
+	minFileSize    int64
+	followSymlinks bool
+	extensions     map[string]bool
+}
+
+// NewScanFilter 编译扫描选项
+func NewScanFilter(opts ScanOptions) (*ScanFilter, error) {
+	f := &ScanFilter{
+		minFileSize:    opts.MinFileSize,
+		followSymlinks: opts.FollowSymlinks,
+		extensions:     make(map[string]bool),
+	}
+
+	for _, ext := range videoExtensions {
+		f.extensions[ext] = true
+	}
+	for _, ext := range opts.ExtraExtensions {
+		if ext = NormalizeExtension(ext); ext != "" {
+			f.extensions[ext] = true
+		}
+	}
+
+	for _, p := range opts.ExcludePatterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if strings.HasPrefix(p, "re:") {
+			re, err := regexp.Compile(strings.TrimPrefix(p, "re:"))
+			if err != nil {
+				return nil, fmt.Errorf("无效的正则规则 %q: %v", p, err)
+			}
+			f.regexps = append(f.regexps, re)
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("无效的通配规则 %q: %v", p, err)
+		}
+		f.globs = append(f.globs, p)
+	}
+
+	return f, nil
+}
+
+// NormalizeExtension 规范化扩展名为小写并带前导点
+func NormalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext == "" {
+		return ""
+	}
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// IsExtraVideoExtension 是否为可按库额外启用的视频扩展名
+func IsExtraVideoExtension(ext string) bool {
+	ext = NormalizeExtension(ext)
+	for _, e := range ExtraVideoExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVideoFile 根据扩展名判断是否为视频文件
+func (f *ScanFilter) IsVideoFile(path string) bool {
+	if f == nil {
+		return IsVideoFile(path)
+	}
+	return f.extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// Excluded 判断文件或目录是否被排除，root 为视频库根目录
+func (f *ScanFilter) Excluded(root, path string, isDir bool) bool {
+	name := filepath.Base(path)
+
+	// 隐藏文件和目录
+	if strings.HasPrefix(name, ".") && path != root {
+		return true
+	}
+	if isDir {
+		lower := strings.ToLower(name)
+		for _, d := range builtinExcludeDirs {
+			if lower == d {
+				return true
+			}
+		}
+	}
+
+	if f == nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, g := range f.globs {
+		if ok, _ := filepath.Match(g, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(g, rel); ok {
+			return true
+		}
+	}
+	for _, re := range f.regexps {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptFile 判断文件是否应当入库
+func (f *ScanFilter) AcceptFile(root, path string, info os.FileInfo) bool {
+	if !f.IsVideoFile(path) || f.Excluded(root, path, false) {
+		return false
+	}
+	if f != nil && f.minFileSize > 0 && info.Size() < f.minFileSize {
+		return false
+	}
+	return true
+}
+
+// FollowSymlinks 是否跟随符号链接
+func (f *ScanFilter) FollowSymlinks() bool {
+	return f != nil && f.followSymlinks
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// showinfoTimePattern showinfo 滤镜日志中的帧时间
+var showinfoTimePattern = regexp.MustCompile(`Parsed_showinfo.*\bpts_time:\s*([0-9.]+)`)
+
+// SceneDetectArgs 场景检测的 ffmpeg 参数：缩小画面以加快计算，
+// 场景变化超过阈值的帧由 showinfo 滤镜写入日志，视频本身不输出
+func SceneDetectArgs(threshold float64) []string {
+	return []string{
+		// 不输出以 \r 结尾的进度行，否则整个日志可能被当作一行
+		"-nostats",
+		"-an", "-sn", "-dn",
+		"-vf", fmt.Sprintf("scale=320:-2,select='gt(scene,%.3f)',showinfo", threshold),
+		"-f", "null",
+	}
+}
+
+// ParseSceneTimes 从 ffmpeg 日志中解析场景变化的时间点（秒），按时间排序，
+// 日志中有超长的行无法读取时返回错误
+func ParseSceneTimes(log []byte) ([]float64, error) {
+	var times []float64
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := showinfoTimePattern.FindSubmatch(scanner.Bytes())
+		if m == nil {
+			continue
+		}
+		if t, err := strconv.ParseFloat(string(m[1]), 64); err == nil {
+			times = append(times, t)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("解析场景检测日志失败: %v", err)
+	}
+	sort.Float64s(times)
+	return times, nil
+}
+
+// SceneBoundaries 将场景变化时间点整理为章节起点：第一个章节从 0 开始，
+// 距离上一个起点或视频结尾不足 minInterval 的时间点被忽略，数量超过 maxScenes 时均匀抽取
+func SceneBoundaries(times []float64, duration, minInterval float64, maxScenes int) []float64 {
+	starts := []float64{0}
+	for _, t := range times {
+		if t-starts[len(starts)-1] < minInterval {
+			continue
+		}
+		if duration > 0 && duration-t < minInterval {
+			break
+		}
+		starts = append(starts, t)
+	}
+
+	if maxScenes > 0 && len(starts) > maxScenes {
+		picked := make([]float64, 0, maxScenes)
+		for i := 0; i < maxScenes; i++ {
+			picked = append(picked, starts[i*len(starts)/maxScenes])
+		}
+		starts = picked
+	}
+	return starts
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSceneTimes(t *testing.T) {
+	log := strings.Join([]string{
+		"Input #0, matroska,webm, from 'movie.mkv':",
+		"[Parsed_showinfo_2 @ 0x55d0] n:   0 pts:  95095 pts_time:95.095  duration: 1001",
+		"[Parsed_showinfo_2 @ 0x55d0] n:   1 pts:  12012 pts_time:12.012  duration: 1001",
+		"[Parsed_showinfo_2 @ 0x55d0] color_range:tv color_space:bt709",
+		"[Parsed_showinfo_2 @ 0x55d0] n:   2 pts:  40040 pts_time:40.04   duration: 1001",
+		"frame=  3 fps=0.0 q=-0.0 Lsize=N/A time=00:02:00.00 bitrate=N/A speed= 150x",
+	}, "\n")
+
+	times, err := ParseSceneTimes([]byte(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{12.012, 40.04, 95.095}; !reflect.DeepEqual(times, want) {
+		t.Errorf("ParseSceneTimes = %v, want %v", times, want)
+	}
+
+	// 超出缓冲区的行返回错误，而不是只返回已解析的部分
+	long := "[Parsed_showinfo_2 @ 0x55d0] n: 0 pts: 1 pts_time:1.5\n" + strings.Repeat("x", 2*1024*1024)
+	if _, err := ParseSceneTimes([]byte(long)); err == nil {
+		t.Error("oversized log line accepted")
+	}
+}
+
+func TestSceneBoundaries(t *testing.T) {
+	tests := []struct {
+		times     []float64
+		duration  float64
+		interval  float64
+		maxScenes int
+		want      []float64
+	}{
+		// 距离上一个起点不足最小间隔的时间点被忽略
+		{[]float64{3, 20, 25, 60}, 120, 10, 0, []float64{0, 20, 60}},
+		// 距离结尾不足最小间隔的时间点被忽略
+		{[]float64{30, 115}, 120, 10, 0, []float64{0, 30}},
+		// 时长未知时不按结尾截断
+		{[]float64{30, 115}, 0, 10, 0, []float64{0, 30, 115}},
+		// 超过数量上限时均匀抽取
+		{[]float64{10, 20, 30, 40, 50, 60, 70}, 100, 5, 4, []float64{0, 20, 40, 60}},
+		{nil, 100, 10, 5, []float64{0}},
+	}
+	for _, tt := range tests {
+		got := SceneBoundaries(tt.times, tt.duration, tt.interval, tt.maxScenes)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SceneBoundaries(%v, %v, %v, %d) = %v, want %v", tt.times, tt.duration, tt.interval, tt.maxScenes, got, tt.want)
+		}
+	}
+}
+
+func TestSceneDetectArgs(t *testing.T) {
+	args := SceneDetectArgs(0.3)
+	if args[0] != "-nostats" {
+		t.Errorf("args = %v, want -nostats first", args)
+	}
+	if joined := strings.Join(args, " "); !strings.Contains(joined, fmt.Sprintf("gt(scene,%.3f)", 0.3)) {
+		t.Errorf("threshold missing from %v", args)
+	}
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"hidevideo/backend/config"
+)
+
+// SpriteVTTName 缩略图 WebVTT 文件名
+const SpriteVTTName = "thumbnails.vtt"
+
+// SpriteDir 视频的拖动预览缩略图目录
+func SpriteDir(videoID uint) string {
+	return filepath.Join(config.SpriteConfig.Dir, fmt.Sprintf("%d", videoID))
+}
+
+// RemoveSprites 删除视频的拖动预览缩略图
+func RemoveSprites(videoID uint) error {
+	return os.RemoveAll(SpriteDir(videoID))
+}
+
+// GenerateSprites 按固定间隔截帧并拼成雪碧图，同时生成 WebVTT 缩略图轨道，返回 VTT 文件路径
+// duration 为 0 时自动获取视频时长，ctx 结束时停止截帧并删除已生成的文件
+func GenerateSprites(ctx context.Context, videoPath string, videoID uint, duration float64) (string, error) {
+	if duration <= 0 {
+		info, err := GetVideoInfo(videoPath)
+		if err != nil {
+			return "", err
+		}
+		duration = info.Duration
+	}
+	if duration <= 0 {
+		return "", fmt.Errorf("无法获取视频时长")
+	}
+
+	cfg := config.SpriteConfig
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 10
+	}
+	if cfg.MaxFrames > 0 && duration/interval > float64(cfg.MaxFrames) {
+		interval = duration / float64(cfg.MaxFrames)
+	}
+	count := int(math.Ceil(duration / interval))
+
+	// 重新生成时清除旧文件
+	dir := SpriteDir(videoID)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	tmpDir, err := os.MkdirTemp(dir, "frames")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 截取全部帧，失败的帧在拼图中留空
+	frames := make([]image.Image, count)
+	var cellW, cellH int
+	for i := 0; i < count; i++ {
+		if err := ctx.Err(); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		framePath := filepath.Join(tmpDir, fmt.Sprintf("%d.jpg", i))
+		opts := FrameOptions{Width: cfg.TileWidth, Height: cfg.TileHeight, Quality: 5, Context: ctx}
+		if err := Media.ExtractFrame(videoPath, float64(i)*interval, framePath, opts); err != nil {
+			continue
+		}
+		img, err := decodeJPEG(framePath)
+		if err != nil {
+			continue
+		}
+		frames[i] = img
+		if cellW == 0 {
+			cellW, cellH = img.Bounds().Dx(), img.Bounds().Dy()
+		}
+	}
+	if cellW == 0 {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("截取缩略图失败")
+	}
+
+	columns, rows := cfg.Columns, cfg.Rows
+	if columns <= 0 || rows <= 0 {
+		columns, rows = 10, 10
+	}
+	perSheet := columns * rows
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n\n")
+	for sheet := 0; sheet*perSheet < count; sheet++ {
+		first := sheet * perSheet
+		n := perSheet
+		if count-first < n {
+			n = count - first
+		}
+		usedRows := (n + columns - 1) / columns
+		usedCols := columns
+		if n < columns {
+			usedCols = n
+		}
+
+		canvas := image.NewRGBA(image.Rect(0, 0, usedCols*cellW, usedRows*cellH))
+		sheetName := fmt.Sprintf("sprite_%d.jpg", sheet)
+		for j := 0; j < n; j++ {
+			i := first + j
+			x, y := (j%columns)*cellW, (j/columns)*cellH
+			if frames[i] != nil {
+				draw.Draw(canvas, image.Rect(x, y, x+cellW, y+cellH), frames[i], frames[i].Bounds().Min, draw.Src)
+			}
+
+			start := float64(i) * interval
+			end := math.Min(start+interval, duration)
+			fmt.Fprintf(&vtt, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n",
+				vttTimestamp(start), vttTimestamp(end), sheetName, x, y, cellW, cellH)
+		}
+
+		if err := encodeJPEG(filepath.Join(dir, sheetName), canvas); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	vttPath := filepath.Join(dir, SpriteVTTName)
+	if err := os.WriteFile(vttPath, []byte(vtt.String()), 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return vttPath, nil
+}
+
+// vttTimestamp 格式化为 WebVTT 时间戳 HH:MM:SS.mmm
+func vttTimestamp(seconds float64) string {
+	ms := int64(math.Round(seconds * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// decodeJPEG 读取 JPEG 图片
+func decodeJPEG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return jpeg.Decode(f)
+}
+
+// encodeJPEG 保存 JPEG 图片
+func encodeJPEG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 80}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSprites(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "movie.mp4")
+	os.WriteFile(videoPath, []byte("sprites"), 0644)
+
+	vttPath, err := GenerateSprites(context.Background(), videoPath, 5, 120)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vtt, err := os.ReadFile(vttPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 120 秒按 10 秒间隔截取 12 帧
+	if n := strings.Count(string(vtt), " --> "); n != 12 {
+		t.Errorf("%d cues in VTT, want 12:\n%s", n, vtt)
+	}
+	if _, err := os.Stat(filepath.Join(SpriteDir(5), "sprite_0.jpg")); err != nil {
+		t.Errorf("sprite sheet missing: %v", err)
+	}
+}
+
+func TestGenerateSpritesCancelled(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "movie.mp4")
+	os.WriteFile(videoPath, []byte("sprites"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := GenerateSprites(ctx, videoPath, 6, 120); err != context.Canceled {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if _, err := os.Stat(SpriteDir(6)); !os.IsNotExist(err) {
+		t.Errorf("sprite dir left behind after cancel: %v", err)
+	}
+}
@@ -0,0 +1,411 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"hidevideo/backend/config"
+	"hidevideo/backend/models"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 字幕来源
+const (
+	SubtitleSidecar  = "sidecar"  // 与视频同名的外挂字幕文件
+	SubtitleEmbedded = "embedded" // 视频内嵌的字幕流
+	SubtitleUpload   = "upload"   // 用户上传的字幕文件
+)
+
+// subtitleExtensions 支持的字幕文件扩展名
+var subtitleExtensions = []string{".srt", ".ass", ".ssa", ".vtt"}
+
+// textSubtitleCodecs 可以转换为 WebVTT 的内嵌字幕编码，图形字幕（PGS、VobSub 等）不支持
+var textSubtitleCodecs = []string{"subrip", "srt", "ass", "ssa", "webvtt", "mov_text", "text"}
+
+// subtitleLanguagePattern 外挂字幕文件名中的语言代码，如 en、chi、zh-CN、pt_BR、zh-Hans
+var subtitleLanguagePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}([-_][a-zA-Z]{2,4})?$`)
+
+// assTagPattern ASS 字幕的样式标签，如 {\an8}{\i1}
+var assTagPattern = regexp.MustCompile(`\{[^}]*\}`)
+
+// subtitleExtraction 正在提取的内嵌字幕，同一字幕流同时只提取一次
+type subtitleExtraction struct {
+	done chan struct{}
+	err  error
+}
+
+var (
+	extracting   = make(map[string]*subtitleExtraction)
+	extractingMu sync.Mutex
+)
+
+// subtitleCue 一条字幕
+type subtitleCue struct {
+	Start float64
+	End   float64
+	Text  string
+}
+
+// SubtitleDir 视频的上传字幕及内嵌字幕提取结果目录
+func SubtitleDir(videoID uint) string {
+	return filepath.Join(config.SubtitleConfig.Dir, fmt.Sprintf("%d", videoID))
+}
+
+// RemoveSubtitleFiles 删除视频的上传字幕和内嵌字幕提取结果（不删除外挂字幕）
+func RemoveSubtitleFiles(videoID uint) error {
+	return os.RemoveAll(SubtitleDir(videoID))
+}
+
+// SubtitleFormat 根据扩展名返回字幕格式（srt、ass、ssa、vtt），不是字幕文件时返回空字符串
+func SubtitleFormat(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, subtitleExt := range subtitleExtensions {
+		if ext == subtitleExt {
+			return ext[1:]
+		}
+	}
+	return ""
+}
+
+// IsTextSubtitle 判断内嵌字幕编码能否转换为 WebVTT
+func IsTextSubtitle(codec string) bool {
+	for _, c := range textSubtitleCodecs {
+		if codec == c {
+			return true
+		}
+	}
+	return false
+}
+
+// FindSidecarSubtitles 查找与视频同名的外挂字幕，文件名中视频名之后的部分识别为语言和标记，
+// 如 movie.zh-CN.forced.srt；dirCache 不为空时缓存目录内容，扫描同一目录下的多个视频时避免重复读取
+func FindSidecarSubtitles(videoPath string, dirCache map[string][]os.DirEntry) []models.Subtitle {
+	dir := filepath.Dir(videoPath)
+	entries, ok := dirCache[dir]
+	if !ok {
+		entries, _ = os.ReadDir(dir)
+		if dirCache != nil {
+			dirCache[dir] = entries
+		}
+	}
+
+	base := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	var subtitles []models.Subtitle
+	for _, entry := range entries {
+		name := entry.Name()
+		format := SubtitleFormat(name)
+		if entry.IsDir() || format == "" {
+			continue
+		}
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if stem != base && !strings.HasPrefix(stem, base+".") {
+			continue
+		}
+
+		// 视频名之后的第一段必须是语言代码或字幕标记，movie.part2.srt 不是 movie.mkv 的字幕
+		tokens := strings.Split(strings.TrimPrefix(stem[len(base):], "."), ".")
+		if stem != base && !isSidecarToken(tokens[0]) {
+			continue
+		}
+
+		sub := models.Subtitle{Source: SubtitleSidecar, Path: filepath.Join(dir, name), Format: format}
+		var title []string
+		for _, token := range tokens {
+			switch strings.ToLower(token) {
+			case "":
+			case "forced":
+				sub.Forced = true
+			case "default":
+				sub.Default = true
+			case "sdh", "cc", "hi":
+				title = append(title, strings.ToUpper(token))
+			default:
+				if sub.Language == "" && subtitleLanguagePattern.MatchString(token) {
+					sub.Language = strings.ToLower(token)
+				} else {
+					title = append(title, token)
+				}
+			}
+		}
+		sub.Title = strings.Join(title, " ")
+		subtitles = append(subtitles, sub)
+	}
+	return subtitles
+}
+
+// isSidecarToken 是否为外挂字幕文件名中的语言代码或字幕标记
+func isSidecarToken(token string) bool {
+	switch strings.ToLower(token) {
+	case "forced", "default", "sdh", "cc", "hi":
+		return true
+	}
+	return subtitleLanguagePattern.MatchString(token)
+}
+
+// EmbeddedSubtitles 从探测结果中获取内嵌字幕流
+func (p *ProbeData) EmbeddedSubtitles() []models.Subtitle {
+	var subtitles []models.Subtitle
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		if s.CodecType != "subtitle" {
+			continue
+		}
+		subtitles = append(subtitles, models.Subtitle{
+			Source:      SubtitleEmbedded,
+			StreamIndex: s.Index,
+			Format:      s.CodecName,
+			Language:    s.Tag("language"),
+			Title:       s.Tag("title"),
+			Default:     s.Disposition["default"] == 1,
+			Forced:      s.Disposition["forced"] == 1,
+		})
+	}
+	return subtitles
+}
+
+// ExtractSubtitle 将内嵌字幕流提取为 WebVTT 文件并缓存，返回文件路径
+// run 执行 ffmpeg 转码，由调用方控制并发；同一字幕流同时请求时只提取一次，其余请求等待结果
+func ExtractSubtitle(ctx context.Context, videoPath string, videoID uint, streamIndex int, run func(context.Context, TranscodeOptions) error) (string, error) {
+	dst := filepath.Join(SubtitleDir(videoID), fmt.Sprintf("embedded_%d.vtt", streamIndex))
+	for {
+		extractingMu.Lock()
+		if FileExists(dst) {
+			extractingMu.Unlock()
+			return dst, nil
+		}
+		ex, ok := extracting[dst]
+		if !ok {
+			break
+		}
+		extractingMu.Unlock()
+
+		select {
+		case <-ex.done:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		// 发起提取的客户端断开时提取被终止，仍在等待的请求重新提取
+		if ex.err != nil && !errors.Is(ex.err, context.Canceled) {
+			return "", ex.err
+		}
+	}
+	ex := &subtitleExtraction{done: make(chan struct{})}
+	extracting[dst] = ex
+	extractingMu.Unlock()
+
+	ex.err = extractSubtitle(ctx, videoPath, dst, streamIndex, run)
+
+	extractingMu.Lock()
+	delete(extracting, dst)
+	extractingMu.Unlock()
+	close(ex.done)
+
+	if ex.err != nil {
+		return "", ex.err
+	}
+	return dst, nil
+}
+
+// extractSubtitle 提取到临时文件，完成后重命名，中断时不留下不完整的字幕
+func extractSubtitle(ctx context.Context, videoPath, dst string, streamIndex int, run func(context.Context, TranscodeOptions) error) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := fmt.Sprintf("%s.%d.tmp", dst, time.Now().UnixNano())
+	err := run(ctx, TranscodeOptions{
+		Input:  videoPath,
+		Args:   []string{"-map", fmt.Sprintf("0:%d", streamIndex), "-c:s", "webvtt", "-f", "webvtt"},
+		Output: tmp,
+	})
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// ToWebVTT 将 SRT、ASS/SSA 字幕转换为 WebVTT，VTT 字幕原样返回
+func ToWebVTT(data []byte, format string) ([]byte, error) {
+	text, err := decodeSubtitleText(data)
+	if err != nil {
+		return nil, err
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var cues []subtitleCue
+	switch format {
+	case "vtt":
+		if !strings.HasPrefix(text, "WEBVTT") {
+			return nil, fmt.Errorf("无效的 WebVTT 文件")
+		}
+		return []byte(text), nil
+	case "srt":
+		cues = parseSRT(text)
+	case "ass", "ssa":
+		cues = parseASS(text)
+	default:
+		return nil, fmt.Errorf("不支持的字幕格式: %s", format)
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("字幕文件中没有字幕内容")
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+
+	var vtt strings.Builder
+	vtt.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		fmt.Fprintf(&vtt, "%s --> %s\n%s\n\n", vttTimestamp(cue.Start), vttTimestamp(cue.End), cue.Text)
+	}
+	return []byte(vtt.String()), nil
+}
+
+// decodeSubtitleText 将字幕文件内容转换为 UTF-8：按 BOM 识别 UTF-8/UTF-16，
+// 没有 BOM 且不是有效的 UTF-8 时按 GB18030（兼容 GBK、GB2312）解码
+func decodeSubtitleText(data []byte) (string, error) {
+	switch {
+	case len(data) >= 3 && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF:
+		return string(data[3:]), nil
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE:
+		out, err := unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		return string(out), err
+	case len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF:
+		out, err := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		return string(out), err
+	case utf8.Valid(data):
+		return string(data), nil
+	}
+	out, err := simplifiedchinese.GB18030.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("无法识别字幕文件的编码")
+	}
+	return string(out), nil
+}
+
+// parseSRT 解析 SRT 字幕，每条字幕由序号、时间行和文本组成，以空行分隔
+func parseSRT(text string) []subtitleCue {
+	var cues []subtitleCue
+	for _, block := range strings.Split(text, "\n\n") {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		for i, line := range lines {
+			parts := strings.Split(line, "-->")
+			if len(parts) != 2 {
+				continue
+			}
+			// 时间后可能带有坐标等附加信息
+			endFields := strings.Fields(parts[1])
+			if len(endFields) == 0 {
+				break
+			}
+			start, ok1 := parseSubtitleTime(parts[0])
+			end, ok2 := parseSubtitleTime(endFields[0])
+			body := cueText(lines[i+1:])
+			if ok1 && ok2 && body != "" {
+				cues = append(cues, subtitleCue{Start: start, End: end, Text: body})
+			}
+			break
+		}
+	}
+	return cues
+}
+
+// parseASS 解析 ASS/SSA 字幕 [Events] 段中的 Dialogue 行，去除样式标签
+func parseASS(text string) []subtitleCue {
+	fields := []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+	inEvents := false
+
+	var cues []subtitleCue
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "format":
+			fields = fields[:0]
+			for _, f := range strings.Split(value, ",") {
+				fields = append(fields, strings.ToLower(strings.TrimSpace(f)))
+			}
+		case "dialogue":
+			values := strings.SplitN(value, ",", len(fields))
+			if len(values) != len(fields) {
+				continue
+			}
+			var cue subtitleCue
+			var ok1, ok2 bool
+			for i, f := range fields {
+				switch f {
+				case "start":
+					cue.Start, ok1 = parseSubtitleTime(values[i])
+				case "end":
+					cue.End, ok2 = parseSubtitleTime(values[i])
+				case "text":
+					body := assTagPattern.ReplaceAllString(values[i], "")
+					body = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(body)
+					cue.Text = cueText(strings.Split(body, "\n"))
+				}
+			}
+			if ok1 && ok2 && cue.Text != "" {
+				cues = append(cues, cue)
+			}
+		}
+	}
+	return cues
+}
+
+// parseSubtitleTime 解析 HH:MM:SS,mmm、H:MM:SS.cc 或 MM:SS.mmm 格式的时间（秒）
+func parseSubtitleTime(s string) (float64, bool) {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(s), ",", "."), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var seconds float64
+	for _, part := range parts {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, true
+}
+
+// cueText 合并字幕文本行，去除空行（WebVTT 中空行表示字幕结束）
+func cueText(lines []string) string {
+	var kept []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
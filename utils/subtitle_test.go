@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExtractSubtitleOnce(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "movie.mkv")
+	os.WriteFile(videoPath, []byte("subtitles"), 0644)
+
+	var runs int32
+	run := func(ctx context.Context, opts TranscodeOptions) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(20 * time.Millisecond)
+		return Media.Transcode(ctx, opts)
+	}
+
+	// 同一字幕流同时请求时只提取一次，所有请求得到同一文件
+	var wg sync.WaitGroup
+	paths := make([]string, 5)
+	errs := make([]error, 5)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = ExtractSubtitle(context.Background(), videoPath, 8, 2, run)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range paths {
+		if errs[i] != nil || paths[i] != paths[0] {
+			t.Errorf("request %d = %q %v, want %q", i, paths[i], errs[i], paths[0])
+		}
+	}
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("subtitle extracted %d times, want 1", n)
+	}
+	entries, _ := os.ReadDir(SubtitleDir(8))
+	if len(entries) != 1 || entries[0].Name() != "embedded_2.vtt" {
+		t.Errorf("subtitle dir = %v, want only embedded_2.vtt", entries)
+	}
+
+	// 已提取的字幕直接返回缓存
+	if _, err := ExtractSubtitle(context.Background(), videoPath, 8, 2, run); err != nil || atomic.LoadInt32(&runs) != 1 {
+		t.Errorf("cached subtitle extracted again: %v", err)
+	}
+}
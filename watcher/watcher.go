@@ -0,0 +1,249 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"hidevideo/backend/utils"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Event 视频库文件变化事件
+type Event struct {
+	LibraryID uint
+	Path      string
+	Removed   bool // 为 true 时表示文件或目录已被删除/移走
+}
+
+// HandlerFunc 文件变化处理函数
+type HandlerFunc func(ev Event)
+
+var (
+	// Debounce 文件最后一次变化后等待的时间
+	Debounce = 3 * time.Second
+
+	// QueueSize 每个视频库等待处理的事件数，队列已满时暂停读取文件系统事件
+	QueueSize = 256
+
+	handler  HandlerFunc
+	watchers = make(map[uint]*libraryWatcher)
+	mu       sync.Mutex
+)
+
+// SetHandler 设置文件变化处理函数，需在 Watch 之前调用
+func SetHandler(h HandlerFunc) {
+	mu.Lock()
+	defer mu.Unlock()
+	handler = h
+}
+
+// Watch 开始递归监听视频库目录，已在监听时先停止旧的监听
+func Watch(libraryID uint, root string, filter *utils.ScanFilter) error {
+	Unwatch(libraryID)
+
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	w := &libraryWatcher{
+		libraryID: libraryID,
+		root:      root,
+		filter:    filter,
+		fw:        fw,
+		pending:   make(map[string]*pendingFile),
+		events:    make(chan Event, QueueSize),
+		done:      make(chan struct{}),
+	}
+	if err := w.addRecursive(root); err != nil {
+		fw.Close()
+		return err
+	}
+
+	mu.Lock()
+	watchers[libraryID] = w
+	mu.Unlock()
+
+	go w.loop()
+	go w.work()
+	return nil
+}
+
+// Unwatch 停止监听视频库
+func Unwatch(libraryID uint) {
+	mu.Lock()
+	w, ok := watchers[libraryID]
+	delete(watchers, libraryID)
+	mu.Unlock()
+
+	if ok {
+		close(w.done)
+		w.fw.Close()
+	}
+}
+
+// IsWatching 视频库是否正在监听
+func IsWatching(libraryID uint) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	_, ok := watchers[libraryID]
+	return ok
+}
+
+// pendingFile 等待写入完成的文件
+type pendingFile struct {
+	lastEvent time.Time
+	size      int64
+}
+
+// libraryWatcher 单个视频库的监听器
+type libraryWatcher struct {
+	libraryID uint
+	root      string
+	filter    *utils.ScanFilter
+	fw        *fsnotify.Watcher
+	pending   map[string]*pendingFile
+	events    chan Event
+	done      chan struct{}
+}
+
+// addRecursive 监听目录及其全部子目录
+func (w *libraryWatcher) addRecursive(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// 子目录无法访问时跳过
+			if path != dir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && w.filter.Excluded(w.root, path, true) {
+				return filepath.SkipDir
+			}
+			return w.fw.Add(path)
+		}
+		return nil
+	})
+}
+
+// loop 处理文件系统事件，定时检查等待中的文件
+func (w *libraryWatcher) loop() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			w.handleEvent(ev)
+		case _, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+		case <-ticker.C:
+			w.flushPending()
+		}
+	}
+}
+
+// handleEvent 处理单个文件系统事件
+func (w *libraryWatcher) handleEvent(ev fsnotify.Event) {
+	switch {
+	case ev.Has(fsnotify.Create):
+		info, err := os.Stat(ev.Name)
+		if err != nil {
+			return
+		}
+		if info.IsDir() {
+			if w.filter.Excluded(w.root, ev.Name, true) {
+				return
+			}
+			// 新目录（包括移入的目录）需要监听并处理其中已有的文件
+			w.addRecursive(ev.Name)
+			files, _ := utils.GetVideoFiles(ev.Name, w.filter)
+			for _, path := range files {
+				w.touch(path)
+			}
+			return
+		}
+		w.touch(ev.Name)
+	case ev.Has(fsnotify.Write):
+		w.touch(ev.Name)
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		delete(w.pending, ev.Name)
+		w.emit(Event{LibraryID: w.libraryID, Path: ev.Name, Removed: true})
+	}
+}
+
+// touch 记录文件变化，等待写入稳定后再处理
+func (w *libraryWatcher) touch(path string) {
+	if !w.filter.IsVideoFile(path) || w.filter.Excluded(w.root, path, false) {
+		return
+	}
+	if p, ok := w.pending[path]; ok {
+		p.lastEvent = time.Now()
+		return
+	}
+	w.pending[path] = &pendingFile{lastEvent: time.Now(), size: -1}
+}
+
+// flushPending 处理已停止增长的文件
+func (w *libraryWatcher) flushPending() {
+	now := time.Now()
+	for path, p := range w.pending {
+		if now.Sub(p.lastEvent) < Debounce {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(w.pending, path)
+			continue
+		}
+
+		// 文件大小仍在变化时继续等待
+		if info.Size() != p.size {
+			p.size = info.Size()
+			p.lastEvent = now
+			continue
+		}
+
+		delete(w.pending, path)
+		if w.filter.AcceptFile(w.root, path, info) {
+			w.emit(Event{LibraryID: w.libraryID, Path: path})
+		}
+	}
+}
+
+// emit 将事件交给处理协程，探测、计算指纹等耗时操作不阻塞文件系统事件的读取
+func (w *libraryWatcher) emit(ev Event) {
+	select {
+	case w.events <- ev:
+	case <-w.done:
+	}
+}
+
+// work 按顺序调用处理函数，同一视频库的事件依次处理
+func (w *libraryWatcher) work() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev := <-w.events:
+			mu.Lock()
+			h := handler
+			mu.Unlock()
+			if h != nil {
+				h(ev)
+			}
+		}
+	}
+}